@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type field struct {
+	key       string
+	name      string
+	path      []embed
+	index     []int
+	tagged    bool
+	omitempty bool
+	required  bool
+	utc       bool
+	typ       ast.Expr
+}
+
+// embed is an embedded or inlined struct through which a field is promoted.
+type embed struct {
+	name string
+	// typ is the name of the struct type if it is embedded by pointer.
+	typ string
+}
+
+// sel returns the selector of the field on the receiver x.
+func (f field) sel() string {
+	var b strings.Builder
+	b.WriteString("x.")
+	for _, e := range f.path {
+		b.WriteString(e.name + ".")
+	}
+	b.WriteString(f.name)
+	return b.String()
+}
+
+type generator struct {
+	buf          bytes.Buffer
+	tag          string
+	pkg          string
+	specs        map[string]*ast.TypeSpec
+	needsReflect bool
+	needsTime    bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate parses the Go files in dir and returns the formatted source of the
+// generated methods for the given types.
+func generate(dir string, types []string, tag string) ([]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg string
+	fset := token.NewFileSet()
+	specs := make(map[string]*ast.TypeSpec)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		pkg = f.Name.Name
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				specs[ts.Name.Name] = ts
+				return false
+			}
+			return true
+		})
+	}
+
+	g := &generator{tag: tag, pkg: pkg, specs: specs}
+	for _, name := range types {
+		ts, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		} else if ts.TypeParams != nil {
+			return nil, fmt.Errorf("type %s: generic types are not supported", name)
+		}
+
+		fields, err := g.fields(name, st)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %w", name, err)
+		}
+		g.generate(name, fields)
+	}
+
+	body := g.buf.Bytes()
+	g.buf = bytes.Buffer{}
+	g.printf("// Code generated by surgo-gen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n")
+	g.printf("\t\"fmt\"\n")
+	if g.needsReflect {
+		g.printf("\t\"reflect\"\n")
+	}
+	if g.needsTime {
+		g.printf("\t\"time\"\n")
+	}
+	g.printf("\n")
+	g.printf("\t\"github.com/NoBypass/surgo/v2/errs\"\n")
+	g.printf("\t\"github.com/NoBypass/surgo/v2/marshal\"\n")
+	g.printf(")\n")
+	g.buf.Write(body)
+
+	return format.Source(g.buf.Bytes())
+}
+
+// fields resolves the struct fields the same way marshal.Codec does,
+// including the promotion of embedded and inlined struct fields.
+func (g *generator) fields(name string, st *ast.StructType) ([]field, error) {
+	type queued struct {
+		name  string
+		st    *ast.StructType
+		path  []embed
+		index []int
+	}
+
+	var fields []field
+	visited := make(map[string]bool)
+	for next := []queued{{name: name, st: st}}; len(next) > 0; {
+		current := next
+		next = nil
+
+		for _, q := range current {
+			if visited[q.name] {
+				continue
+			}
+			visited[q.name] = true
+
+			i := 0
+			for _, f := range q.st.Fields.List {
+				var tag reflect.StructTag
+				if f.Tag != nil {
+					s, _ := strconv.Unquote(f.Tag.Value)
+					tag = reflect.StructTag(s)
+				}
+				dbTag := tag.Get("db")
+				if dbTag == "" && g.tag != "" {
+					dbTag = tag.Get(g.tag)
+				}
+				vals := strings.Split(dbTag, ",")
+				tagged := vals[0] != ""
+
+				names := f.Names
+				embedded := len(names) == 0
+				if embedded {
+					names = []*ast.Ident{ast.NewIdent(embeddedName(f.Type))}
+				}
+
+				for _, n := range names {
+					index := append(slices.Clone(q.index), i)
+					i++
+					if vals[0] == "-" {
+						continue
+					}
+
+					typeName, ptr, isStruct, err := g.structType(f.Type)
+					if err != nil && (embedded && !tagged || slices.Contains(vals[1:], "inline")) {
+						return nil, err
+					}
+					inline := isStruct && (embedded && !tagged || slices.Contains(vals[1:], "inline"))
+
+					// the exported fields of unexported embedded structs are
+					// promoted too, unless they are behind a pointer.
+					if inline && (ast.IsExported(n.Name) || embedded && !ptr) {
+						e := embed{name: n.Name}
+						if ptr {
+							e.typ = typeName
+						}
+						st := g.specs[typeName].Type.(*ast.StructType)
+						next = append(next, queued{typeName, st, append(slices.Clone(q.path), e), index})
+						continue
+					} else if !ast.IsExported(n.Name) {
+						continue
+					}
+
+					key := vals[0]
+					if !tagged {
+						key = n.Name
+					}
+					fields = append(fields, field{
+						key:       key,
+						name:      n.Name,
+						path:      q.path,
+						index:     index,
+						tagged:    tagged,
+						omitempty: slices.Contains(vals[1:], "omitempty"),
+						required:  slices.Contains(vals[1:], "required"),
+						utc:       slices.Contains(vals[1:], "utc") && isTime(f.Type),
+						typ:       f.Type,
+					})
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(fields, func(a, b field) int {
+		return cmp.Or(
+			strings.Compare(a.key, b.key),
+			cmp.Compare(len(a.index), len(b.index)),
+			compareBool(b.tagged, a.tagged),
+		)
+	})
+
+	dominant := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].key == fields[i].key {
+			j++
+		}
+		if j-i == 1 || len(fields[i].index) < len(fields[i+1].index) || fields[i].tagged && !fields[i+1].tagged {
+			dominant = append(dominant, fields[i])
+		}
+		i = j
+	}
+
+	slices.SortFunc(dominant, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return dominant, nil
+}
+
+// structType resolves typ to a struct type declared in the parsed package.
+// It returns an error for types from other packages, as their fields are
+// unknown, except for time.Time which is never inlined.
+func (g *generator) structType(typ ast.Expr) (name string, ptr, ok bool, err error) {
+	if star, isStar := typ.(*ast.StarExpr); isStar {
+		typ, ptr = star.X, true
+	}
+
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if ts, found := g.specs[t.Name]; found {
+			_, ok = ts.Type.(*ast.StructType)
+			return t.Name, ptr, ok, nil
+		}
+		return "", false, false, nil
+	case *ast.SelectorExpr:
+		if isTime(typ) {
+			return "", false, false, nil
+		}
+		return "", false, false, fmt.Errorf("cannot inline %s as it is declared in another package", t.Sel.Name)
+	default:
+		return "", false, false, nil
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func (g *generator) generate(name string, fields []field) {
+	g.printf("\n// MarshalSurreal implements marshal.SurrealMarshaler.\n")
+	g.printf("func (x %s) MarshalSurreal() map[string]any {\n", name)
+	g.printf("resolved := make(map[string]any, %d)\n", len(fields))
+	for _, f := range fields {
+		sel := f.sel()
+		val := sel
+		if f.utc {
+			val = g.utc(f.typ, sel)
+		}
+
+		var conds []string
+		for i, e := range f.path {
+			if e.typ != "" {
+				conds = append(conds, field{name: e.name, path: f.path[:i]}.sel()+" != nil")
+			}
+		}
+		if f.omitempty {
+			conds = append(conds, g.nonZero(f.typ, sel))
+		}
+
+		if len(conds) > 0 {
+			g.printf("if %s {\n", strings.Join(conds, " && "))
+			g.printf("resolved[%q] = %s\n", f.key, val)
+			g.printf("}\n")
+		} else {
+			g.printf("resolved[%q] = %s\n", f.key, val)
+		}
+	}
+	g.printf("return resolved\n")
+	g.printf("}\n")
+
+	g.printf("\n// UnmarshalSurreal implements marshal.SurrealUnmarshaler.\n")
+	g.printf("func (x *%s) UnmarshalSurreal(m *marshal.Codec, src any) error {\n", name)
+	g.printf("obj, ok := src.(map[string]any)\n")
+	g.printf("if !ok {\n")
+	g.printf("return &errs.UnmarshalError{Err: fmt.Errorf(\"cannot decode %%T into %s.%s\", src)}\n", g.pkg, name)
+	g.printf("}\n")
+
+	var known, required []string
+	for _, f := range fields {
+		known = append(known, strconv.Quote(f.key))
+		if f.required {
+			required = append(required, strconv.Quote(f.key))
+		}
+	}
+	g.printf("if m.Strict {\n")
+	g.printf("known := []string{%s}\n", strings.Join(known, ", "))
+	if len(required) > 0 {
+		g.printf("if err := m.CheckFields(obj, known, []string{%s}); err != nil {\n", strings.Join(required, ", "))
+	} else {
+		g.printf("if err := m.CheckFields(obj, known, nil); err != nil {\n")
+	}
+	g.printf("return err\n")
+	g.printf("}\n")
+	g.printf("}\n")
+
+	for _, f := range fields {
+		sel := f.sel()
+		g.printf("if v, ok := obj[%q]; ok {\n", f.key)
+		for i, e := range f.path {
+			if e.typ != "" {
+				ptr := field{name: e.name, path: f.path[:i]}.sel()
+				g.printf("if %s == nil {\n", ptr)
+				g.printf("%s = new(%s)\n", ptr, e.typ)
+				g.printf("}\n")
+			}
+		}
+		g.printf("if err := m.UnmarshalField(%q, v, &%s); err != nil {\n", f.key, sel)
+		g.printf("return err\n")
+		g.printf("}\n")
+		if f.utc {
+			g.printf("%s = %s\n", sel, g.utc(f.typ, sel))
+		}
+		// fields behind a nil embedded pointer are zero already
+		var guards []string
+		for i, e := range f.path {
+			if e.typ != "" {
+				guards = append(guards, field{name: e.name, path: f.path[:i]}.sel()+" != nil")
+			}
+		}
+		if len(guards) > 0 {
+			g.printf("} else if %s {\n", strings.Join(guards, " && "))
+		} else {
+			g.printf("} else {\n")
+		}
+		g.printf("m.ResetField(&%s)\n", sel)
+		g.printf("}\n")
+	}
+	g.printf("return nil\n")
+	g.printf("}\n")
+}
+
+// nonZero returns an expression which reports whether sel holds a non-zero
+// value of typ. Types which cannot be resolved syntactically fall back to
+// reflection so that omitempty behaves exactly like the reflective Marshaler.
+func (g *generator) nonZero(typ ast.Expr, sel string) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return sel + ` != ""`
+		case "bool":
+			return sel
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "complex64", "complex128", "byte", "rune":
+			return sel + " != 0"
+		case "any", "error":
+			return sel + " != nil"
+		}
+	case *ast.StarExpr, *ast.MapType, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return sel + " != nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return sel + " != nil"
+		}
+	}
+	g.needsReflect = true
+	return "!reflect.ValueOf(" + sel + ").IsZero()"
+}
+
+// utc returns an expression converting sel to UTC. typ must be either
+// time.Time or *time.Time.
+func (g *generator) utc(typ ast.Expr, sel string) string {
+	if _, ok := typ.(*ast.StarExpr); ok {
+		g.needsTime = true
+		return "func(t *time.Time) *time.Time {\n" +
+			"if t == nil {\nreturn nil\n}\n" +
+			"utc := t.UTC()\nreturn &utc\n" +
+			"}(" + sel + ")"
+	}
+	return sel + ".UTC()"
+}
+
+func isTime(typ ast.Expr) bool {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	sel, ok := typ.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "time" && sel.Sel.Name == "Time"
+}
+
+func embeddedName(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	const dir = "../../marshal/internal/gentest"
+
+	t.Run("output is up to date", func(t *testing.T) {
+		want, err := os.ReadFile(dir + "/user_surgo.go")
+		assert.NoError(t, err)
+
+		got, err := generate(dir, []string{"User", "Address"}, "json")
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+	})
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := generate(dir, []string{"Unknown"}, "")
+		assert.Error(t, err)
+	})
+	t.Run("non struct type", func(t *testing.T) {
+		_, err := generate("../../marshal", []string{"SurrealMarshaler"}, "")
+		assert.Error(t, err)
+	})
+}
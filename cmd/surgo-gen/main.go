@@ -0,0 +1,57 @@
+// Command surgo-gen generates MarshalSurreal and UnmarshalSurreal methods for
+// structs so that marshal.Codec does not have to use reflection to walk
+// their fields. It is meant to be used with go:generate:
+//
+//	//go:generate go run github.com/NoBypass/surgo/v2/cmd/surgo-gen -type=User,Address -tag=json
+//
+// The generated methods follow the same tag rules as marshal.Codec. As the
+// fallback tag is resolved at generation time, -tag has to match the fallback
+// tag of the Marshaler the types are used with.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("surgo-gen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; must be set")
+	tag := flag.String("tag", "", "fallback struct tag used if a field has no db tag")
+	output := flag.String("output", "", "output file name; default <type>_surgo.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: surgo-gen [flags] -type T[,T...] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	src, err := generate(dir, types, *tag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := *output
+	if name == "" {
+		name = strings.ToLower(types[0]) + "_surgo.go"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
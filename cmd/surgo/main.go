@@ -0,0 +1,117 @@
+// Command surgo is a command line tool for SurrealDB databases used with
+// surgo.
+//
+// Usage:
+//
+//	surgo <command> [flags]
+//
+// The commands are:
+//
+//	structs  generate Go structs from the schema of a database
+//
+// The structs command reads the schema with INFO FOR DB and INFO FOR TABLE
+// and writes a struct with db tags for every table. SurrealQL types are
+// mapped to Go types, e.g. datetime to time.Time, duration to time.Duration
+// and option<T> to a pointer. Go names which clash, e.g. of the fields
+// first_name and firstName, get a numeric suffix like FirstName2. Assertions
+// are kept as comments:
+//
+//	surgo structs -url ws://localhost:8000 -ns test -db test -user root -package models -output models.go
+//
+// The password is read from the SURREAL_PASS environment variable if -pass is
+// not set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/schema"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("surgo: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "structs":
+		structs(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		log.Printf("unknown command %q", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: surgo <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  structs  generate Go structs from the schema of a database\n")
+}
+
+func structs(args []string) {
+	fs := flag.NewFlagSet("structs", flag.ExitOnError)
+	url := fs.String("url", "ws://localhost:8000", "URL of the SurrealDB instance")
+	ns := fs.String("ns", "", "namespace")
+	db := fs.String("db", "", "database; must be set")
+	user := fs.String("user", "", "user to sign in with")
+	pass := fs.String("pass", os.Getenv("SURREAL_PASS"), "password of the user; default $SURREAL_PASS")
+	pkg := fs.String("package", "models", "package name of the generated file")
+	tables := fs.String("tables", "", "comma-separated list of tables; default all tables")
+	output := fs.String("output", "", "output file name; default standard output")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: surgo structs [flags] -db database\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *db == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := surgo.Connect(*url, &surgo.Credentials{
+		Namespace: *ns,
+		Database:  *db,
+		Username:  *user,
+		Password:  *pass,
+	}, surgo.WithDisableLogging())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	d, err := schema.Introspect(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tables != "" {
+		names := strings.Split(*tables, ",")
+		d.Tables = slices.DeleteFunc(d.Tables, func(t schema.Table) bool {
+			return !slices.Contains(names, t.Name)
+		})
+	}
+
+	src, err := generateStructs(d, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*output, src, 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
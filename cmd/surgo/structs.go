@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/schema"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms are name parts which are written in upper case, following the
+// Go naming conventions.
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "ttl": true, "uri": true, "url": true, "uuid": true,
+}
+
+// geometries are the Go types of the SurrealQL geometry kinds.
+var geometries = map[string]string{
+	"point":        "geo.Point",
+	"line":         "geo.LineString",
+	"polygon":      "geo.Polygon",
+	"multipoint":   "geo.MultiPoint",
+	"multiline":    "geo.MultiLineString",
+	"multipolygon": "geo.MultiPolygon",
+	"collection":   "geo.GeometryCollection",
+}
+
+type structGenerator struct {
+	buf        bytes.Buffer
+	needsTime  bool
+	needsSurgo bool
+	needsGeo   bool
+
+	// fields are the field types of the current table by path and children
+	// the paths of the nested fields by the path of their parent.
+	fields   map[string]string
+	children map[string][]string
+	// nested are the structs of nested objects which are written after the
+	// struct of the table.
+	nested []nestedStruct
+	// types are the names of the structs in the file.
+	types map[string]bool
+}
+
+type nestedStruct struct {
+	name, path string
+}
+
+func (g *structGenerator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generateStructs returns the formatted source of a struct for every table of
+// the database, named after the table. Objects with defined fields become
+// structs of their own, named after the table and the field.
+func generateStructs(d *schema.Database, pkg string) ([]byte, error) {
+	g := &structGenerator{types: make(map[string]bool)}
+	// the structs of the tables are named first, so that nested structs get
+	// the suffix if their names clash
+	names := make([]string, len(d.Tables))
+	for i, t := range d.Tables {
+		names[i] = unique(g.types, goName(t.Name))
+	}
+	for i, t := range d.Tables {
+		g.table(t, names[i])
+	}
+
+	body := g.buf.Bytes()
+	g.buf = bytes.Buffer{}
+	g.printf("// Code generated by surgo structs. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	if g.needsTime || g.needsSurgo || g.needsGeo {
+		g.printf("import (\n")
+		if g.needsTime {
+			g.printf("\t\"time\"\n\n")
+		}
+		if g.needsSurgo {
+			g.printf("\t\"github.com/NoBypass/surgo/v2\"\n")
+		}
+		if g.needsGeo {
+			g.printf("\t\"github.com/NoBypass/surgo/v2/geo\"\n")
+		}
+		g.printf(")\n")
+	}
+	g.buf.Write(body)
+
+	return format.Source(g.buf.Bytes())
+}
+
+func (g *structGenerator) table(t schema.Table, name string) {
+	g.fields = make(map[string]string, len(t.Fields))
+	g.children = make(map[string][]string)
+	for _, f := range t.Fields {
+		g.fields[f.Name] = f.Type
+		parent := parentPath(f.Name)
+		g.children[parent] = append(g.children[parent], f.Name)
+	}
+	byPath := make(map[string]schema.Field, len(t.Fields))
+	for _, f := range t.Fields {
+		byPath[f.Name] = f
+	}
+
+	g.printf("\n// %s is a record of the %s table.\n", name, t.Name)
+	if t.Comment != "" {
+		g.printf("//\n")
+		writeComment(&g.buf, "", t.Comment)
+	}
+	g.printf("type %s struct {\n", name)
+	g.printf("\tID string `db:\"id\"`\n")
+	g.structFields(name, "", byPath)
+	g.printf("}\n")
+
+	for len(g.nested) > 0 {
+		s := g.nested[0]
+		g.nested = g.nested[1:]
+		g.printf("\n// %s is the %s field of the %s table.\n", s.name, strings.TrimSuffix(s.path, "[*]"), t.Name)
+		g.printf("type %s struct {\n", s.name)
+		g.structFields(s.name, s.path, byPath)
+		g.printf("}\n")
+	}
+}
+
+// structFields writes the fields nested in the path of the struct.
+func (g *structGenerator) structFields(structName, path string, fields map[string]schema.Field) {
+	used := make(map[string]bool)
+	if path == "" {
+		used["ID"] = true
+	}
+	for _, p := range g.children[path] {
+		key := p[strings.LastIndex(p, ".")+1:]
+		if strings.HasSuffix(p, "[*]") || path == "" && key == "id" {
+			// elements are part of the type of their array
+			continue
+		}
+
+		f := fields[p]
+		name := unique(used, goName(key))
+		writeComment(&g.buf, "\t", f.Comment)
+		if f.Assert != "" {
+			writeComment(&g.buf, "\t", "ASSERT "+f.Assert)
+		}
+		g.printf("\t%s %s `db:\"%s\"`\n", name, g.goType(f.Type, p, structName+name), key)
+	}
+}
+
+// goType returns the Go type of the SurrealQL type of the field at the path.
+// name is the name of the struct if the field is an object with nested
+// fields.
+func (g *structGenerator) goType(typ, path, name string) string {
+	var alternatives []string
+	var nullable bool
+	for _, alt := range splitType(typ, '|') {
+		if alt = strings.TrimSpace(alt); alt == "null" {
+			nullable = true
+		} else if alt != "" {
+			alternatives = append(alternatives, alt)
+		}
+	}
+	if len(alternatives) == 0 && len(g.children[path]) > 0 {
+		// fields without a type are objects if they have nested fields
+		alternatives = []string{"object"}
+	} else if len(alternatives) != 1 {
+		return "any"
+	}
+
+	kind, args := parseType(alternatives[0])
+	var t string
+	switch kind {
+	case "option":
+		inner := "any"
+		if len(args) > 0 {
+			inner = g.goType(args[0], path, name)
+		}
+		if nilable(inner) || strings.HasPrefix(inner, "surgo.Nullable[") {
+			return inner
+		}
+		return "*" + inner
+	case "bool", "string", "int":
+		t = kind
+	case "float", "number":
+		t = "float64"
+	case "decimal":
+		g.needsSurgo = true
+		t = "surgo.Decimal"
+	case "uuid":
+		g.needsSurgo = true
+		t = "surgo.UUID"
+	case "datetime":
+		g.needsTime = true
+		t = "time.Time"
+	case "duration":
+		g.needsTime = true
+		t = "time.Duration"
+	case "bytes":
+		t = "[]byte"
+	case "record":
+		t = "string"
+	case "array", "set":
+		elem, ok := g.fields[path+"[*]"]
+		if len(args) > 0 {
+			elem, ok = args[0], true
+		}
+		if !ok {
+			elem = "any"
+		}
+		t = "[]" + g.goType(elem, path+"[*]", name)
+	case "object":
+		if len(g.children[path]) == 0 {
+			t = "map[string]any"
+		} else {
+			t = unique(g.types, name)
+			g.nested = append(g.nested, nestedStruct{name: t, path: path})
+		}
+	case "geometry":
+		// geo.Geometry is an interface which can't be unmarshaled into, so
+		// only fields with a single kind of geometry get a geo type
+		if len(args) != 1 || geometries[strings.TrimSpace(args[0])] == "" {
+			return "any"
+		}
+		g.needsGeo = true
+		t = geometries[strings.TrimSpace(args[0])]
+	default:
+		return "any"
+	}
+
+	if nullable && !nilable(t) {
+		g.needsSurgo = true
+		return "surgo.Nullable[" + t + "]"
+	}
+	return t
+}
+
+// parseType splits a SurrealQL type like array<string, 10> into its kind and
+// its arguments.
+func parseType(typ string) (string, []string) {
+	kind, args, ok := strings.Cut(typ, "<")
+	if !ok || !strings.HasSuffix(args, ">") {
+		return strings.TrimSpace(typ), nil
+	}
+	return strings.TrimSpace(kind), splitType(strings.TrimSuffix(args, ">"), ',')
+}
+
+// splitType splits typ at sep outside of angle brackets and literals.
+func splitType(typ string, sep rune) []string {
+	var parts []string
+	var depth, start int
+	var quote rune
+	for i, r := range typ {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '<' || r == '(' || r == '[' || r == '{':
+			depth++
+		case r == '>' || r == ')' || r == ']' || r == '}':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, typ[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, typ[start:])
+}
+
+// parentPath returns the path of the field a nested field is part of, e.g.
+// tags for tags[*] and tags[*] for tags[*].name.
+func parentPath(path string) string {
+	if p, ok := strings.CutSuffix(path, "[*]"); ok {
+		return p
+	} else if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func nilable(t string) bool {
+	return t == "any" || strings.HasPrefix(t, "*") || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[")
+}
+
+// unique returns name, or name with the smallest numeric suffix from 2 on
+// which is not used yet, and marks it as used.
+func unique(used map[string]bool, name string) string {
+	n := name
+	for i := 2; used[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	used[n] = true
+	return n
+}
+
+// goName converts a SurrealDB name like first_name to an exported Go name
+// like FirstName.
+func goName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		rs := []rune(part)
+		rs[0] = unicode.ToUpper(rs[0])
+		b.WriteString(string(rs))
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return "X" + name
+	}
+	return name
+}
+
+func writeComment(buf *bytes.Buffer, indent, comment string) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(buf, "%s// %s\n", indent, line)
+	}
+}
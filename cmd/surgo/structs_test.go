@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"github.com/NoBypass/surgo/v2/schema"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateStructs(t *testing.T) {
+	t.Run("types", func(t *testing.T) {
+		d := &schema.Database{Tables: []schema.Table{
+			{Name: "audit_log"},
+			{
+				Name:    "user",
+				Comment: "Registered users.\nDeleted users are kept.",
+				Fields: []schema.Field{
+					{Name: "address", Type: "option<object>"},
+					{Name: "address.city", Type: "string"},
+					{Name: "address.geo_location", Type: "geometry<point>"},
+					{Name: "age", Type: "option<int>"},
+					{Name: "avatar", Type: "option<bytes>"},
+					{Name: "balance", Type: "decimal"},
+					{Name: "created", Type: "datetime", Readonly: true},
+					{Name: "email", Type: "string", Assert: "string::is::email($value)", Comment: "used to sign in"},
+					{Name: "friends", Type: "array<record<user>, 10>"},
+					{Name: "home_url", Type: "string | null"},
+					{Name: "id", Type: "record<user>"},
+					{Name: "nickname", Type: "option<string | null>"},
+					{Name: "previous", Type: "array<object>"},
+					{Name: "previous[*].city", Type: "string"},
+					{Name: "score", Type: "number"},
+					{Name: "session_ttl", Type: "duration"},
+					{Name: "settings", Type: "object", Flexible: true},
+					{Name: "shape", Type: "geometry<polygon | multipolygon>"},
+					{Name: "spot", Type: "option<geometry>"},
+					{Name: "tags", Type: "set"},
+					{Name: "tags[*]", Type: "string"},
+					{Name: "token", Type: "uuid"},
+					{Name: "value", Type: "int | string"},
+					{Name: "verified", Type: "bool"},
+				},
+			},
+		}}
+
+		got, err := generateStructs(d, "models")
+		assert.NoError(t, err)
+
+		const path = "testdata/structs.golden"
+		if *update {
+			assert.NoError(t, os.WriteFile(path, got, 0o644))
+		}
+		want, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+		assertCompiles(t, got)
+	})
+	t.Run("name clashes", func(t *testing.T) {
+		d := &schema.Database{Tables: []schema.Table{
+			{Name: "user", Fields: []schema.Field{
+				{Name: "firstName", Type: "string"},
+				{Name: "first_name", Type: "string"},
+				{Name: "i_d", Type: "int"},
+				{Name: "profile", Type: "object"},
+				{Name: "profile.bio", Type: "string"},
+				{Name: "profile.b_io", Type: "string"},
+			}},
+			{Name: "user_profile", Fields: []schema.Field{{Name: "bio", Type: "string"}}},
+			{Name: "user-profile"},
+		}}
+
+		got, err := generateStructs(d, "models")
+		assert.NoError(t, err)
+		assert.Equal(t, `// Code generated by surgo structs. DO NOT EDIT.
+
+package models
+
+// User is a record of the user table.
+type User struct {
+	ID         string       `+"`db:\"id\"`"+`
+	FirstName  string       `+"`db:\"firstName\"`"+`
+	FirstName2 string       `+"`db:\"first_name\"`"+`
+	ID2        int          `+"`db:\"i_d\"`"+`
+	Profile    UserProfile3 `+"`db:\"profile\"`"+`
+}
+
+// UserProfile3 is the profile field of the user table.
+type UserProfile3 struct {
+	Bio string `+"`db:\"bio\"`"+`
+	BIo string `+"`db:\"b_io\"`"+`
+}
+
+// UserProfile is a record of the user_profile table.
+type UserProfile struct {
+	ID  string `+"`db:\"id\"`"+`
+	Bio string `+"`db:\"bio\"`"+`
+}
+
+// UserProfile2 is a record of the user-profile table.
+type UserProfile2 struct {
+	ID string `+"`db:\"id\"`"+`
+}
+`, string(got))
+		assertCompiles(t, got)
+	})
+	t.Run("no imports", func(t *testing.T) {
+		got, err := generateStructs(&schema.Database{Tables: []schema.Table{{Name: "post", Fields: []schema.Field{{Name: "title", Type: "string"}}}}}, "db")
+		assert.NoError(t, err)
+		assert.Equal(t, "// Code generated by surgo structs. DO NOT EDIT.\n\npackage db\n\n// Post is a record of the post table.\ntype Post struct {\n\tID    string `db:\"id\"`\n\tTitle string `db:\"title\"`\n}\n", string(got))
+	})
+}
+
+// assertCompiles type checks the generated source.
+func assertCompiles(t *testing.T, src []byte) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "structs.go", src, 0)
+	assert.NoError(t, err)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("models", fset, []*ast.File{f}, nil)
+	assert.NoError(t, err)
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"name":       "Name",
+		"first_name": "FirstName",
+		"home-url":   "HomeURL",
+		"user id":    "UserID",
+		"2fa":        "X2fa",
+		"":           "X",
+	} {
+		assert.Equal(t, want, goName(in), in)
+	}
+}
@@ -0,0 +1,113 @@
+package errs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DatabaseError is an error reported by SurrealDB, either for a single
+// statement or for the whole request. errors.Is reports true for ErrDatabase
+// and for the error it is classified as, e.g. ErrAlreadyExists.
+type DatabaseError struct {
+	// Kind is the classification of the error or nil if it is unknown.
+	Kind *SurgoError
+	// Message is the message sent by SurrealDB.
+	Message string
+	// Code is the code of the JSON-RPC error, 0 for failed statements.
+	Code int
+	// Statement is the index of the failed statement or -1 if the whole
+	// request failed.
+	Statement int
+	// Line and Column are the position of an ErrParse, starting at 1, or 0 if
+	// the message does not contain a position.
+	Line, Column int
+	// Field is the name of the field of an ErrFieldValidation.
+	Field string
+	// Err is the original error, e.g. an *rpc.Error.
+	Err error
+}
+
+var (
+	parsePosition   = regexp.MustCompile(`line (\d+),? column (\d+)|\[(\d+):(\d+)]`)
+	validationField = regexp.MustCompile("(?i)for field `([^`]+)`")
+)
+
+// kinds maps fragments of SurrealDB messages to the error they are classified
+// as. They are checked in order, so the more specific fragments come first,
+// e.g. a parse error mentioning a missing token is not classified as
+// ErrNotFound and a failed assertion mentioning a conflict is not retried as
+// ErrTransactionConflict.
+var kinds = []struct {
+	fragments []string
+	kind      *SurgoError
+}{
+	{[]string{"parse error", "failed to parse"}, ErrParse},
+	{[]string{"token has expired", "session has expired", "token expired", "session expired"}, ErrAuthExpired},
+	{[]string{"not enough permissions", "permission denied", "not allowed"}, ErrPermissionDenied},
+	{[]string{"for field `"}, ErrFieldValidation},
+	{[]string{"conflict", "can be retried"}, ErrTransactionConflict},
+	{[]string{"already exists", "already contains"}, ErrAlreadyExists},
+	{[]string{"does not exist", "not found"}, ErrNotFound},
+}
+
+// NewDatabaseError classifies the message of a SurrealDB error. code is the
+// code of the JSON-RPC error and statement the index of the failed statement,
+// see DatabaseError.
+func NewDatabaseError(message string, code, statement int, err error) *DatabaseError {
+	e := &DatabaseError{
+		Message:   message,
+		Code:      code,
+		Statement: statement,
+		Err:       err,
+	}
+
+	lower := strings.ToLower(message)
+	for _, k := range kinds {
+		if containsAny(lower, k.fragments) {
+			e.Kind = k.kind
+			break
+		}
+	}
+
+	switch e.Kind {
+	case ErrParse:
+		if m := parsePosition.FindStringSubmatch(message); m != nil {
+			e.Line, _ = strconv.Atoi(m[1] + m[3])
+			e.Column, _ = strconv.Atoi(m[2] + m[4])
+		}
+	case ErrFieldValidation:
+		if m := validationField.FindStringSubmatch(message); m != nil {
+			e.Field = m[1]
+		}
+	}
+	return e
+}
+
+func (e *DatabaseError) Error() string {
+	if e.Statement < 0 {
+		return e.Message
+	}
+	return "statement " + strconv.Itoa(e.Statement) + ": " + e.Message
+}
+
+// Unwrap returns ErrDatabase, the kind and the original error of e.
+func (e *DatabaseError) Unwrap() []error {
+	errs := []error{ErrDatabase}
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package errs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewDatabaseError(t *testing.T) {
+	tests := []struct {
+		message string
+		kind    *SurgoError
+	}{
+		{"Database record `users:john` already exists", ErrAlreadyExists},
+		{"Database index `email` already contains 'a@b.c', with record `users:john`", ErrAlreadyExists},
+		{"The table 'users' does not exist", ErrNotFound},
+		{"IAM error: Not enough permissions to perform this action", ErrPermissionDenied},
+		{"Parse error: Failed to parse query at line 1 column 8 expected query to end", ErrParse},
+		{"Failed to commit transaction due to a read or write conflict. This transaction can be retried", ErrTransactionConflict},
+		{"Found 'abc' for field `age`, with record `users:john`, but expected a int", ErrFieldValidation},
+		{"There was a problem with authentication: The token has expired", ErrAuthExpired},
+		{"Something unexpected happened", nil},
+	}
+	for _, test := range tests {
+		err := NewDatabaseError(test.message, 0, 1, nil)
+		assert.ErrorIs(t, err, ErrDatabase, test.message)
+		assert.Equal(t, test.kind, err.Kind, test.message)
+		if test.kind != nil {
+			assert.ErrorIs(t, err, test.kind, test.message)
+		}
+	}
+
+	t.Run("parse position", func(t *testing.T) {
+		for _, message := range []string{
+			"Parse error: Failed to parse query at line 3 column 14 expected query to end",
+			"Parse error: Unexpected token `an identifier`, expected Eof\n --> [3:14]\n  |\n3 | SELEC * FROM users",
+		} {
+			err := NewDatabaseError(message, 0, 0, nil)
+			assert.ErrorIs(t, err, ErrParse)
+			assert.Equal(t, 3, err.Line, message)
+			assert.Equal(t, 14, err.Column, message)
+		}
+	})
+	t.Run("validation field", func(t *testing.T) {
+		err := NewDatabaseError("Found NONE for field `email`, with record `users:john`, but field must conform to: $value != NONE", 0, 0, nil)
+		assert.Equal(t, "email", err.Field)
+
+		err = NewDatabaseError("For field `email` the value is invalid", 0, 0, nil)
+		assert.Equal(t, "email", err.Field)
+
+		err = NewDatabaseError("Found 'x' for field `", 0, 0, nil)
+		assert.ErrorIs(t, err, ErrFieldValidation)
+		assert.Empty(t, err.Field)
+	})
+	t.Run("validation mentioning a conflict", func(t *testing.T) {
+		err := NewDatabaseError("Found 'a' for field `status`, with record `users:john`, but field must conform to: $value != 'conflict'", 0, 0, nil)
+		assert.ErrorIs(t, err, ErrFieldValidation)
+		assert.NotErrorIs(t, err, ErrTransactionConflict)
+		assert.Equal(t, "status", err.Field)
+	})
+	t.Run("errors.As", func(t *testing.T) {
+		original := errors.New("rpc error")
+		var err error = NewDatabaseError("The token has expired", -32000, -1, original)
+
+		var dbErr *DatabaseError
+		if assert.ErrorAs(t, err, &dbErr) {
+			assert.Equal(t, -32000, dbErr.Code)
+			assert.Equal(t, -1, dbErr.Statement)
+		}
+		assert.ErrorIs(t, err, original)
+		assert.EqualError(t, err, "The token has expired")
+		assert.EqualError(t, NewDatabaseError("boom", 0, 2, nil), "statement 2: boom")
+	})
+}
@@ -19,6 +19,20 @@ var (
 	ErrUnmarshal            = &SurgoError{fmt.Errorf("unmarshal error")}
 	ErrMarshal              = &SurgoError{fmt.Errorf("marshal error")}
 	ErrUnexpectedResponseID = &SurgoError{fmt.Errorf("unexpected response id")}
+	ErrUnsupported          = &SurgoError{fmt.Errorf("not supported by the server")}
+	ErrTxDone               = &SurgoError{fmt.Errorf("transaction has already been committed or rolled back")}
+	ErrVars                 = &SurgoError{fmt.Errorf("invalid vars")}
+	ErrMigration            = &SurgoError{fmt.Errorf("migration error")}
+	ErrLocked               = &SurgoError{fmt.Errorf("locked by another process")}
+
+	// The following errors classify a DatabaseError.
+	ErrAlreadyExists       = &SurgoError{fmt.Errorf("already exists")}
+	ErrNotFound            = &SurgoError{fmt.Errorf("not found")}
+	ErrPermissionDenied    = &SurgoError{fmt.Errorf("permission denied")}
+	ErrParse               = &SurgoError{fmt.Errorf("parse error")}
+	ErrTransactionConflict = &SurgoError{fmt.Errorf("transaction conflict")}
+	ErrFieldValidation     = &SurgoError{fmt.Errorf("field validation failed")}
+	ErrAuthExpired         = &SurgoError{fmt.Errorf("authentication expired")}
 )
 
 func (e *SurgoError) With(err error) error {
@@ -36,3 +50,24 @@ func (e *SurgoError) Error() string {
 func (e *SurgoError) Unwrap() error {
 	return e.Err
 }
+
+// UnmarshalError is returned if a value could not be unmarshaled. Path is the
+// location of the value in the source, e.g. orders[3].items[0].price, and is
+// empty if the error occurred at the top level.
+type UnmarshalError struct {
+	Path string
+	Err  error
+}
+
+func (e *UnmarshalError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns ErrUnmarshal and the underlying error, so that errors.Is
+// reports true for both.
+func (e *UnmarshalError) Unwrap() []error {
+	return []error{ErrUnmarshal, e.Err}
+}
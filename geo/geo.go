@@ -0,0 +1,299 @@
+// Package geo contains the geometry types of SurrealDB. They are marshaled to
+// and from GeoJSON objects by marshal.Codec, so they can be used directly
+// as struct fields or query variables.
+//
+// surgo only talks to SurrealDB over JSON, so the geometries are always
+// GeoJSON. The CBOR geometry tags are not supported.
+package geo
+
+import (
+	"fmt"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+)
+
+// Geometry is implemented by all geometry types of this package.
+type Geometry interface {
+	marshal.SurrealMarshaler
+	// Type returns the GeoJSON type of the geometry, e.g. Point.
+	Type() string
+}
+
+type (
+	// Point is a single position. X is the longitude and Y the latitude.
+	Point struct {
+		X, Y float64
+	}
+	// LineString is a line through two or more points.
+	LineString []Point
+	// Polygon consists of linear rings. The first ring is the exterior,
+	// all other rings are holes in it.
+	Polygon []LineString
+	// MultiPoint is a collection of points.
+	MultiPoint []Point
+	// MultiLineString is a collection of lines.
+	MultiLineString []LineString
+	// MultiPolygon is a collection of polygons.
+	MultiPolygon []Polygon
+	// GeometryCollection is a collection of arbitrary geometries.
+	GeometryCollection []Geometry
+)
+
+func (Point) Type() string              { return "Point" }
+func (LineString) Type() string         { return "LineString" }
+func (Polygon) Type() string            { return "Polygon" }
+func (MultiPoint) Type() string         { return "MultiPoint" }
+func (MultiLineString) Type() string    { return "MultiLineString" }
+func (MultiPolygon) Type() string       { return "MultiPolygon" }
+func (GeometryCollection) Type() string { return "GeometryCollection" }
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (p Point) MarshalSurreal() map[string]any {
+	return geoJSON(p, p.coordinates())
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (l LineString) MarshalSurreal() map[string]any {
+	return geoJSON(l, l.coordinates())
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (p Polygon) MarshalSurreal() map[string]any {
+	return geoJSON(p, p.coordinates())
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (mp MultiPoint) MarshalSurreal() map[string]any {
+	return geoJSON(mp, LineString(mp).coordinates())
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (ml MultiLineString) MarshalSurreal() map[string]any {
+	return geoJSON(ml, Polygon(ml).coordinates())
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (mp MultiPolygon) MarshalSurreal() map[string]any {
+	coordinates := make([]any, len(mp))
+	for i, p := range mp {
+		coordinates[i] = p.coordinates()
+	}
+	return geoJSON(mp, coordinates)
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (c GeometryCollection) MarshalSurreal() map[string]any {
+	geometries := make([]any, len(c))
+	for i, g := range c {
+		geometries[i] = g.MarshalSurreal()
+	}
+	return map[string]any{
+		"type":       c.Type(),
+		"geometries": geometries,
+	}
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler. Besides GeoJSON,
+// points can also be decoded from a plain [x, y] array.
+func (p *Point) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [2]float64
+	if _, ok := src.([]any); ok {
+		if err := m.Unmarshal(src, &c); err != nil {
+			return err
+		}
+	} else if err := decode(m, src, p.Type(), &c); err != nil {
+		return err
+	}
+
+	*p = Point{c[0], c[1]}
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (l *LineString) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [][2]float64
+	if err := decode(m, src, l.Type(), &c); err != nil {
+		return err
+	}
+
+	*l = toLineString(c)
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (p *Polygon) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [][][2]float64
+	if err := decode(m, src, p.Type(), &c); err != nil {
+		return err
+	}
+
+	*p = toPolygon(c)
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (mp *MultiPoint) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [][2]float64
+	if err := decode(m, src, mp.Type(), &c); err != nil {
+		return err
+	}
+
+	*mp = MultiPoint(toLineString(c))
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (ml *MultiLineString) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [][][2]float64
+	if err := decode(m, src, ml.Type(), &c); err != nil {
+		return err
+	}
+
+	*ml = MultiLineString(toPolygon(c))
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (mp *MultiPolygon) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	var c [][][][2]float64
+	if err := decode(m, src, mp.Type(), &c); err != nil {
+		return err
+	}
+
+	*mp = make(MultiPolygon, len(c))
+	for i, p := range c {
+		(*mp)[i] = toPolygon(p)
+	}
+	return nil
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (c *GeometryCollection) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	obj, err := object(src, c.Type())
+	if err != nil {
+		return err
+	}
+
+	var geometries []anyGeometry
+	if err := m.UnmarshalField("geometries", obj["geometries"], &geometries); err != nil {
+		return err
+	}
+
+	*c = make(GeometryCollection, len(geometries))
+	for i, g := range geometries {
+		(*c)[i] = g.Geometry
+	}
+	return nil
+}
+
+// Unmarshal decodes a GeoJSON object of any geometry type. The returned
+// Geometry is a value of one of the types of this package, e.g. Point.
+func Unmarshal(m *marshal.Codec, src any) (Geometry, error) {
+	obj, err := object(src, "")
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := obj["type"]; t {
+	case "Point":
+		return unmarshalAs[Point](m, obj)
+	case "LineString":
+		return unmarshalAs[LineString](m, obj)
+	case "Polygon":
+		return unmarshalAs[Polygon](m, obj)
+	case "MultiPoint":
+		return unmarshalAs[MultiPoint](m, obj)
+	case "MultiLineString":
+		return unmarshalAs[MultiLineString](m, obj)
+	case "MultiPolygon":
+		return unmarshalAs[MultiPolygon](m, obj)
+	case "GeometryCollection":
+		return unmarshalAs[GeometryCollection](m, obj)
+	default:
+		return nil, &errs.UnmarshalError{Path: "type", Err: fmt.Errorf("unknown geometry type %v", t)}
+	}
+}
+
+func unmarshalAs[T Geometry, P interface {
+	*T
+	marshal.SurrealUnmarshaler
+}](m *marshal.Codec, obj map[string]any) (Geometry, error) {
+	var g T
+	if err := P(&g).UnmarshalSurreal(m, obj); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// anyGeometry decodes a geometry of any type.
+type anyGeometry struct {
+	Geometry
+}
+
+func (g *anyGeometry) UnmarshalSurreal(m *marshal.Codec, src any) (err error) {
+	g.Geometry, err = Unmarshal(m, src)
+	return err
+}
+
+func geoJSON(g Geometry, coordinates []any) map[string]any {
+	return map[string]any{
+		"type":        g.Type(),
+		"coordinates": coordinates,
+	}
+}
+
+// object returns src as a GeoJSON object of the given type. If typ is empty,
+// any type is accepted.
+func object(src any, typ string) (map[string]any, error) {
+	obj, ok := src.(map[string]any)
+	if !ok {
+		return nil, &errs.UnmarshalError{Err: fmt.Errorf("cannot decode %T into a geometry", src)}
+	} else if t := obj["type"]; typ != "" && t != typ {
+		return nil, &errs.UnmarshalError{Err: fmt.Errorf("cannot decode %v geometry into %s", t, typ)}
+	}
+	return obj, nil
+}
+
+func decode(m *marshal.Codec, src any, typ string, coordinates any) error {
+	obj, err := object(src, typ)
+	if err != nil {
+		return err
+	}
+	return m.UnmarshalField("coordinates", obj["coordinates"], coordinates)
+}
+
+func (p Point) coordinates() []any {
+	return []any{p.X, p.Y}
+}
+
+func (l LineString) coordinates() []any {
+	coordinates := make([]any, len(l))
+	for i, p := range l {
+		coordinates[i] = p.coordinates()
+	}
+	return coordinates
+}
+
+func (p Polygon) coordinates() []any {
+	coordinates := make([]any, len(p))
+	for i, l := range p {
+		coordinates[i] = l.coordinates()
+	}
+	return coordinates
+}
+
+func toLineString(c [][2]float64) LineString {
+	l := make(LineString, len(c))
+	for i, p := range c {
+		l[i] = Point{p[0], p[1]}
+	}
+	return l
+}
+
+func toPolygon(c [][][2]float64) Polygon {
+	p := make(Polygon, len(c))
+	for i, l := range c {
+		p[i] = toLineString(l)
+	}
+	return p
+}
@@ -0,0 +1,139 @@
+package geo
+
+import (
+	"encoding/json"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGeometry_Marshal(t *testing.T) {
+	m := marshal.Codec{}
+
+	t.Run("point", func(t *testing.T) {
+		vars := map[string]any{"p": Point{1.5, -2}}
+		m.Marshal(vars)
+		assert.Equal(t, map[string]any{
+			"type":        "Point",
+			"coordinates": []any{1.5, -2.0},
+		}, vars["p"])
+	})
+	t.Run("polygon", func(t *testing.T) {
+		vars := map[string]any{"p": Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}
+		m.Marshal(vars)
+		assert.Equal(t, map[string]any{
+			"type": "Polygon",
+			"coordinates": []any{[]any{
+				[]any{0.0, 0.0}, []any{1.0, 0.0}, []any{1.0, 1.0}, []any{0.0, 0.0},
+			}},
+		}, vars["p"])
+	})
+	t.Run("collection", func(t *testing.T) {
+		vars := map[string]any{"c": GeometryCollection{Point{1, 2}, MultiPoint{{3, 4}}}}
+		m.Marshal(vars)
+		assert.Equal(t, map[string]any{
+			"type": "GeometryCollection",
+			"geometries": []any{
+				map[string]any{"type": "Point", "coordinates": []any{1.0, 2.0}},
+				map[string]any{"type": "MultiPoint", "coordinates": []any{[]any{3.0, 4.0}}},
+			},
+		}, vars["c"])
+	})
+	t.Run("struct fields", func(t *testing.T) {
+		type place struct {
+			Name     string     `json:"name"`
+			Location Point      `json:"location"`
+			Area     *Polygon   `json:"area,omitempty"`
+			Route    LineString `json:"route,omitempty"`
+		}
+		vars := map[string]any{"place": place{Name: "home", Location: Point{8.5, 47.3}}}
+		m.FallbackTag = "json"
+		defer func() { m.FallbackTag = "" }()
+		m.Marshal(vars)
+		assert.Equal(t, map[string]any{
+			"name":     "home",
+			"location": map[string]any{"type": "Point", "coordinates": []any{8.5, 47.3}},
+		}, vars["place"])
+	})
+}
+
+func TestGeometry_Unmarshal(t *testing.T) {
+	m := marshal.Codec{FallbackTag: "json"}
+
+	// decode parses src the way responses are decoded by the rpc package.
+	decode := func(t *testing.T, src string) any {
+		var v any
+		d := json.NewDecoder(strings.NewReader(src))
+		d.UseNumber()
+		assert.NoError(t, d.Decode(&v))
+		return v
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		geometries := []Geometry{
+			Point{1.5, -2},
+			LineString{{0, 0}, {1, 1}},
+			Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}, {{0.2, 0.2}, {0.4, 0.2}, {0.4, 0.4}, {0.2, 0.2}}},
+			MultiPoint{{0, 0}, {1, 1}},
+			MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}},
+			MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}},
+			GeometryCollection{Point{1, 2}, LineString{{0, 0}, {1, 1}}},
+		}
+		for _, g := range geometries {
+			t.Run(g.Type(), func(t *testing.T) {
+				b, err := json.Marshal(g.MarshalSurreal())
+				assert.NoError(t, err)
+
+				res, err := Unmarshal(&m, decode(t, string(b)))
+				assert.NoError(t, err)
+				assert.Equal(t, g, res)
+			})
+		}
+	})
+	t.Run("struct fields", func(t *testing.T) {
+		type place struct {
+			Name     string   `json:"name"`
+			Location Point    `json:"location"`
+			Area     *Polygon `json:"area"`
+		}
+		var p place
+		err := m.Unmarshal(decode(t, `{
+			"name": "home",
+			"location": {"type": "Point", "coordinates": [8.5, 47]},
+			"area": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}
+		}`), &p)
+		assert.NoError(t, err)
+		assert.Equal(t, place{
+			Name:     "home",
+			Location: Point{8.5, 47},
+			Area:     &Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		}, p)
+	})
+	t.Run("point from array", func(t *testing.T) {
+		var p Point
+		err := m.Unmarshal([]any{json.Number("1"), json.Number("2.5")}, &p)
+		assert.NoError(t, err)
+		assert.Equal(t, Point{1, 2.5}, p)
+	})
+	t.Run("wrong geometry type", func(t *testing.T) {
+		var l LineString
+		err := m.Unmarshal(map[string]any{"type": "Point", "coordinates": []any{1.0, 2.0}}, &l)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.EqualError(t, err, "cannot decode Point geometry into LineString")
+	})
+	t.Run("error path", func(t *testing.T) {
+		var p struct {
+			Area Polygon `json:"area"`
+		}
+		err := m.Unmarshal(decode(t, `{"area": {"type": "Polygon", "coordinates": [[[0, 0], [1, "x"]]]}}`), &p)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.EqualError(t, err, "area.coordinates[0][1][1]: cannot decode string into float64")
+	})
+	t.Run("collection error path", func(t *testing.T) {
+		_, err := Unmarshal(&m, decode(t, `{"type": "GeometryCollection", "geometries": [{"type": "Circle"}]}`))
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.EqualError(t, err, "geometries[0].type: unknown geometry type Circle")
+	})
+}
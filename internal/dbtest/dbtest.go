@@ -0,0 +1,22 @@
+// Package dbtest connects a surgo.DB to the fake server of surgotest for the
+// tests of the packages built on top of surgo.
+package dbtest
+
+import (
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/internal/surgotest"
+	"testing"
+)
+
+// New returns a DB connected to a new Server which answers queries with h.
+func New(t *testing.T, h surgotest.Handler, opts ...surgo.Option) (*surgo.DB, *surgotest.Server) {
+	t.Helper()
+
+	s := surgotest.NewServer(t, surgotest.Version, surgotest.QueryHandler(h))
+	db, err := surgo.Connect(s.URL, &surgo.Credentials{}, append([]surgo.Option{surgo.WithDisableLogging()}, opts...)...)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, s
+}
@@ -0,0 +1,147 @@
+// Package surgotest provides a fake SurrealDB server for tests. It does not
+// depend on surgo, so that the tests of surgo itself can use it, see dbtest
+// for a DB connected to it.
+package surgotest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/coder/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Version is the version reported by servers of tests which don't depend on
+// a specific one.
+const Version = "surrealdb-2.1.0"
+
+// Server is a fake SurrealDB server.
+type Server struct {
+	// URL is the websocket URL to connect to.
+	URL string
+
+	mu      sync.Mutex
+	queries []Query
+}
+
+// Query is a query received by the Server.
+type Query struct {
+	Query string
+	Vars  map[string]any
+}
+
+// RPCHandler answers a request. A non-nil *rpc.Error is sent as the error of
+// the response.
+type RPCHandler func(req rpc.Request) (any, *rpc.Error)
+
+// Handler answers a query with the results of its statements. Errors are
+// sent as failed statements.
+type Handler func(q Query) []any
+
+// NewServer starts a Server which answers requests with h and is closed when
+// the test ends. Signin and version requests are answered by the server
+// itself, reporting the given version.
+func NewServer(t *testing.T, version string, h RPCHandler) *Server {
+	t.Helper()
+
+	s := &Server{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+
+		ctx := context.Background()
+		for {
+			_, msg, err := c.Read(ctx)
+			if err != nil {
+				return
+			}
+
+			var req rpc.Request
+			decoder := json.NewDecoder(bytes.NewReader(msg))
+			decoder.UseNumber()
+			if err := decoder.Decode(&req); err != nil {
+				t.Errorf("invalid request: %v", err)
+				return
+			}
+
+			res := rpc.Response{ID: req.ID}
+			switch req.Method {
+			case "signin":
+				res.Result = "token"
+			case "version":
+				res.Result = version
+			default:
+				if req.Method == "query" {
+					s.record(req)
+				}
+				res.Result, res.Error = h(req)
+			}
+
+			b, _ := json.Marshal(res)
+			if err := c.Write(ctx, websocket.MessageText, b); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	s.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return s
+}
+
+// Queries returns the queries received so far.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+func (s *Server) record(req rpc.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query(req))
+}
+
+// QueryHandler returns an RPCHandler which answers queries with h. Other
+// methods are not found.
+func QueryHandler(h Handler) RPCHandler {
+	return func(req rpc.Request) (any, *rpc.Error) {
+		if req.Method != "query" {
+			return nil, &rpc.Error{Code: -32601, Message: "method not found"}
+		}
+		return Statements(h(query(req))...), nil
+	}
+}
+
+// Statements returns the response of a query with the given statement
+// results. Errors are sent as failed statements.
+func Statements(results ...any) []any {
+	res := make([]any, len(results))
+	for i, r := range results {
+		if err, ok := r.(error); ok {
+			res[i] = map[string]any{"status": "ERR", "result": err.Error(), "time": "1ms"}
+		} else {
+			res[i] = map[string]any{"status": "OK", "result": r, "time": "1ms"}
+		}
+	}
+	return res
+}
+
+func query(req rpc.Request) Query {
+	q := Query{}
+	if len(req.Params) > 0 {
+		q.Query, _ = req.Params[0].(string)
+	}
+	if len(req.Params) > 1 {
+		q.Vars, _ = req.Params[1].(map[string]any)
+	}
+	return q
+}
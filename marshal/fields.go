@@ -0,0 +1,194 @@
+package marshal
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// field is a struct field resolved according to the tag rules of a Codec.
+type field struct {
+	name   string
+	index  []int
+	opts   tagOptions
+	tagged bool
+}
+
+// Field is a struct field as resolved by a Codec, see Codec.Fields.
+type Field struct {
+	reflect.StructField
+	// Name is the name of the field in SurrealDB.
+	Name string
+	// Options are the options of the struct tag, e.g. omitempty.
+	Options []string
+}
+
+// Fields returns the fields of the struct type t according to the tag rules of
+// the Codec, including the promoted fields of embedded structs. The Index
+// of a field is relative to t.
+func (m *Codec) Fields(t reflect.Type) []Field {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := m.fields(t)
+	res := make([]Field, len(fields))
+	for i, f := range fields {
+		sf := t.FieldByIndex(f.index)
+		sf.Index = f.index
+		res[i] = Field{StructField: sf, Name: f.name, Options: f.opts}
+	}
+	return res
+}
+
+type fieldCacheKey struct {
+	tag string
+	typ reflect.Type
+}
+
+var fieldCache sync.Map // map[fieldCacheKey][]field
+
+// fields returns the cached fields of the struct type t.
+func (m *Codec) fields(t reflect.Type) []field {
+	key := fieldCacheKey{m.FallbackTag, t}
+	if f, ok := fieldCache.Load(key); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(key, m.typeFields(t))
+	return f.([]field)
+}
+
+// typeFields returns the fields of t including the promoted fields of
+// embedded structs and structs with the inline option. The rules are the
+// same as in encoding/json: embedded structs without a name in their tag are
+// flattened, a field hides fields with the same name at a greater depth, and
+// fields with the same name at the same depth cancel each other out unless
+// exactly one of them is tagged.
+func (m *Codec) typeFields(t reflect.Type) []field {
+	type queued struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []field
+	visited := make(map[reflect.Type]bool)
+	for next := []queued{{typ: t}}; len(next) > 0; {
+		current := next
+		next = nil
+
+		for _, q := range current {
+			if visited[q.typ] {
+				continue
+			}
+			visited[q.typ] = true
+
+			for i := range q.typ.NumField() {
+				sf := q.typ.Field(i)
+				name, opts, tagged := m.parseTag(sf)
+				if name == "-" {
+					continue
+				}
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				index := append(slices.Clone(q.index), i)
+				inline := ft.Kind() == reflect.Struct && ft != timeType &&
+					(sf.Anonymous && !tagged || opts.has("inline"))
+
+				if !sf.IsExported() {
+					// the exported fields of unexported embedded structs are
+					// still promoted, unless they are behind a pointer which
+					// could not be allocated while unmarshaling.
+					if inline && sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+						next = append(next, queued{ft, index})
+					}
+					continue
+				} else if inline {
+					next = append(next, queued{ft, index})
+					continue
+				}
+
+				fields = append(fields, field{
+					name:   name,
+					index:  index,
+					opts:   opts,
+					tagged: tagged,
+				})
+			}
+		}
+	}
+
+	slices.SortStableFunc(fields, func(a, b field) int {
+		return cmp.Or(
+			cmp.Compare(a.name, b.name),
+			cmp.Compare(len(a.index), len(b.index)),
+			compareBool(b.tagged, a.tagged),
+		)
+	})
+
+	dominant := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		// fields are sorted by depth with tagged fields first, so the first
+		// field dominates unless the second one is just as deep and tagged
+		// the same way.
+		if j-i == 1 || len(fields[i].index) < len(fields[i+1].index) || fields[i].tagged && !fields[i+1].tagged {
+			dominant = append(dominant, fields[i])
+		}
+		i = j
+	}
+
+	slices.SortFunc(dominant, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return dominant
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// fieldByIndex returns the field of v at the given index. It reports false
+// if an embedded pointer on the way is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc is like fieldByIndex but allocates nil embedded pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+var timeType = reflect.TypeOf(time.Time{})
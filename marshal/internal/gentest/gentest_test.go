@@ -0,0 +1,121 @@
+package gentest
+
+import (
+	"github.com/NoBypass/surgo/v2/marshal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// plainUser has the same fields and tags as User but none of its generated
+// methods, so it always takes the reflective path.
+type plainUser User
+
+func testUsers() []User {
+	return []User{
+		{},
+		{
+			Base:     Base{ID: "user:1", Created: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Audit:    &Audit{UpdatedBy: "admin", Name: "hidden"},
+			Name:     "john",
+			Email:    "john@example.com",
+			Age:      42,
+			Active:   true,
+			Score:    1.5,
+			Sleep:    8*time.Hour + 30*time.Minute,
+			Birthday: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+			LastSeen: time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.FixedZone("", 2*60*60)),
+			Deleted:  &time.Time{},
+			Tags:     []string{"a", "b"},
+			Meta:     map[string]any{"k": "v"},
+			Home:     &Address{Street: "Main St", Zip: 1234},
+			Work:     Address{Street: "Side St"},
+			Friends:  []Address{{Street: "A"}, {Street: "B", Zip: 1}},
+			Location: Address{Street: "Inline St", Zip: 4321},
+			Ignored:  "ignored",
+		},
+	}
+}
+
+func TestGenerated_Marshal(t *testing.T) {
+	m := marshal.Codec{FallbackTag: "json"}
+
+	for _, u := range testUsers() {
+		generated := m.Marshal(map[string]any{"v": u})
+		reflective := m.Marshal(map[string]any{"v": plainUser(u)})
+		assert.Equal(t, reflective, generated)
+	}
+}
+
+func TestGenerated_Unmarshal(t *testing.T) {
+	m := marshal.Codec{FallbackTag: "json"}
+
+	sources := []any{
+		map[string]any{},
+		map[string]any{
+			"id":        "user:1",
+			"created":   "2020-01-01T00:00:00Z",
+			"name":      "john",
+			"email":     "john@example.com",
+			"age":       float64(42),
+			"Active":    true,
+			"score":     1.5,
+			"sleep":     "8h",
+			"birthday":  "1980-01-01T00:00:00Z",
+			"last_seen": "2024-05-01T12:00:00.123456789+02:00",
+			"deleted":   "2024-05-01T12:00:00.000001+02:00",
+			"tags":      []any{"a", "b"},
+			"meta":      map[string]any{"k": "v"},
+			"home":      map[string]any{"street": "Main St", "zip": float64(1234)},
+			"work":      map[string]any{"street": "Side St"},
+			"friends":   []any{map[string]any{"street": "A"}, map[string]any{"street": "B", "zip": float64(1)}},
+			"unknown":   "ignored",
+		},
+	}
+
+	for _, src := range sources {
+		var generated User
+		var reflective plainUser
+		assert.NoError(t, m.Unmarshal(src, &generated))
+		assert.NoError(t, m.Unmarshal(src, &reflective))
+		assert.Equal(t, User(reflective), generated)
+	}
+}
+
+func TestGenerated_ReuseDestination(t *testing.T) {
+	src := map[string]any{"name": "jane"}
+
+	for _, m := range []marshal.Codec{{FallbackTag: "json"}, {FallbackTag: "json", Merge: true}} {
+		generated := testUsers()[1]
+		reflective := plainUser(testUsers()[1])
+		assert.NoError(t, m.Unmarshal(src, &generated))
+		assert.NoError(t, m.Unmarshal(src, &reflective))
+		assert.Equal(t, User(reflective), generated)
+		assert.Equal(t, "jane", generated.Name)
+		assert.Equal(t, m.Merge, generated.Email != "")
+	}
+}
+
+func TestGenerated_StrictUnmarshal(t *testing.T) {
+	m := marshal.Codec{FallbackTag: "json", Strict: true}
+
+	sources := []any{
+		map[string]any{},
+		map[string]any{"name": "john", "unknown": true},
+		map[string]any{"name": "john", "home": map[string]any{"street": 42}},
+		map[string]any{"name": "john", "friends": []any{map[string]any{}, map[string]any{"zip": 1.5}}},
+		map[string]any{"name": "john", "age": "42"},
+	}
+
+	for _, src := range sources {
+		var generated User
+		var reflective plainUser
+		genErr := m.Unmarshal(src, &generated)
+		refErr := m.Unmarshal(src, &reflective)
+		assert.Error(t, genErr)
+		assert.Equal(t, refErr.Error(), genErr.Error())
+	}
+
+	var u User
+	assert.EqualError(t, m.Unmarshal("user:john", &u), "cannot decode string into gentest.User")
+}
@@ -0,0 +1,45 @@
+// Package gentest contains the fixtures used to check the output of
+// cmd/surgo-gen against the reflective marshal.Codec.
+package gentest
+
+import "time"
+
+//go:generate go run ../../../cmd/surgo-gen -type=User,Address -tag=json
+
+type Base struct {
+	ID      string    `db:"id"`
+	Created time.Time `db:"created"`
+}
+
+type Audit struct {
+	UpdatedBy string `db:"updated_by"`
+	// Name is hidden by User.Name, which is less deeply nested.
+	Name string `db:"name"`
+}
+
+type Address struct {
+	Street string `json:"street"`
+	Zip    int    `json:"zip,omitempty"`
+}
+
+type User struct {
+	Base
+	*Audit
+	Name     string         `db:"name,required"`
+	Email    string         `db:"email,omitempty"`
+	Age      int            `json:"age,omitempty"`
+	Active   bool           `db:",omitempty"`
+	Score    float64        `db:"score"`
+	Sleep    time.Duration  `db:"sleep"`
+	Birthday time.Time      `db:"birthday,omitempty"`
+	LastSeen time.Time      `db:"last_seen,utc"`
+	Deleted  *time.Time     `db:"deleted,omitempty,utc"`
+	Tags     []string       `db:"tags,omitempty"`
+	Meta     map[string]any `db:"meta,omitempty"`
+	Home     *Address       `db:"home,omitempty"`
+	Work     Address        `db:"work"`
+	Friends  []Address      `db:"friends"`
+	Location Address        `db:",inline"`
+	Ignored  string         `db:"-"`
+	internal string
+}
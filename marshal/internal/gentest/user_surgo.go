@@ -0,0 +1,261 @@
+// Code generated by surgo-gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+)
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (x User) MarshalSurreal() map[string]any {
+	resolved := make(map[string]any, 19)
+	resolved["id"] = x.Base.ID
+	resolved["created"] = x.Base.Created
+	if x.Audit != nil {
+		resolved["updated_by"] = x.Audit.UpdatedBy
+	}
+	resolved["name"] = x.Name
+	if x.Email != "" {
+		resolved["email"] = x.Email
+	}
+	if x.Age != 0 {
+		resolved["age"] = x.Age
+	}
+	if x.Active {
+		resolved["Active"] = x.Active
+	}
+	resolved["score"] = x.Score
+	resolved["sleep"] = x.Sleep
+	if !reflect.ValueOf(x.Birthday).IsZero() {
+		resolved["birthday"] = x.Birthday
+	}
+	resolved["last_seen"] = x.LastSeen.UTC()
+	if x.Deleted != nil {
+		resolved["deleted"] = func(t *time.Time) *time.Time {
+			if t == nil {
+				return nil
+			}
+			utc := t.UTC()
+			return &utc
+		}(x.Deleted)
+	}
+	if x.Tags != nil {
+		resolved["tags"] = x.Tags
+	}
+	if x.Meta != nil {
+		resolved["meta"] = x.Meta
+	}
+	if x.Home != nil {
+		resolved["home"] = x.Home
+	}
+	resolved["work"] = x.Work
+	resolved["friends"] = x.Friends
+	resolved["street"] = x.Location.Street
+	if x.Location.Zip != 0 {
+		resolved["zip"] = x.Location.Zip
+	}
+	return resolved
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (x *User) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	obj, ok := src.(map[string]any)
+	if !ok {
+		return &errs.UnmarshalError{Err: fmt.Errorf("cannot decode %T into gentest.User", src)}
+	}
+	if m.Strict {
+		known := []string{"id", "created", "updated_by", "name", "email", "age", "Active", "score", "sleep", "birthday", "last_seen", "deleted", "tags", "meta", "home", "work", "friends", "street", "zip"}
+		if err := m.CheckFields(obj, known, []string{"name"}); err != nil {
+			return err
+		}
+	}
+	if v, ok := obj["id"]; ok {
+		if err := m.UnmarshalField("id", v, &x.Base.ID); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Base.ID)
+	}
+	if v, ok := obj["created"]; ok {
+		if err := m.UnmarshalField("created", v, &x.Base.Created); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Base.Created)
+	}
+	if v, ok := obj["updated_by"]; ok {
+		if x.Audit == nil {
+			x.Audit = new(Audit)
+		}
+		if err := m.UnmarshalField("updated_by", v, &x.Audit.UpdatedBy); err != nil {
+			return err
+		}
+	} else if x.Audit != nil {
+		m.ResetField(&x.Audit.UpdatedBy)
+	}
+	if v, ok := obj["name"]; ok {
+		if err := m.UnmarshalField("name", v, &x.Name); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Name)
+	}
+	if v, ok := obj["email"]; ok {
+		if err := m.UnmarshalField("email", v, &x.Email); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Email)
+	}
+	if v, ok := obj["age"]; ok {
+		if err := m.UnmarshalField("age", v, &x.Age); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Age)
+	}
+	if v, ok := obj["Active"]; ok {
+		if err := m.UnmarshalField("Active", v, &x.Active); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Active)
+	}
+	if v, ok := obj["score"]; ok {
+		if err := m.UnmarshalField("score", v, &x.Score); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Score)
+	}
+	if v, ok := obj["sleep"]; ok {
+		if err := m.UnmarshalField("sleep", v, &x.Sleep); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Sleep)
+	}
+	if v, ok := obj["birthday"]; ok {
+		if err := m.UnmarshalField("birthday", v, &x.Birthday); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Birthday)
+	}
+	if v, ok := obj["last_seen"]; ok {
+		if err := m.UnmarshalField("last_seen", v, &x.LastSeen); err != nil {
+			return err
+		}
+		x.LastSeen = x.LastSeen.UTC()
+	} else {
+		m.ResetField(&x.LastSeen)
+	}
+	if v, ok := obj["deleted"]; ok {
+		if err := m.UnmarshalField("deleted", v, &x.Deleted); err != nil {
+			return err
+		}
+		x.Deleted = func(t *time.Time) *time.Time {
+			if t == nil {
+				return nil
+			}
+			utc := t.UTC()
+			return &utc
+		}(x.Deleted)
+	} else {
+		m.ResetField(&x.Deleted)
+	}
+	if v, ok := obj["tags"]; ok {
+		if err := m.UnmarshalField("tags", v, &x.Tags); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Tags)
+	}
+	if v, ok := obj["meta"]; ok {
+		if err := m.UnmarshalField("meta", v, &x.Meta); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Meta)
+	}
+	if v, ok := obj["home"]; ok {
+		if err := m.UnmarshalField("home", v, &x.Home); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Home)
+	}
+	if v, ok := obj["work"]; ok {
+		if err := m.UnmarshalField("work", v, &x.Work); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Work)
+	}
+	if v, ok := obj["friends"]; ok {
+		if err := m.UnmarshalField("friends", v, &x.Friends); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Friends)
+	}
+	if v, ok := obj["street"]; ok {
+		if err := m.UnmarshalField("street", v, &x.Location.Street); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Location.Street)
+	}
+	if v, ok := obj["zip"]; ok {
+		if err := m.UnmarshalField("zip", v, &x.Location.Zip); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Location.Zip)
+	}
+	return nil
+}
+
+// MarshalSurreal implements marshal.SurrealMarshaler.
+func (x Address) MarshalSurreal() map[string]any {
+	resolved := make(map[string]any, 2)
+	resolved["street"] = x.Street
+	if x.Zip != 0 {
+		resolved["zip"] = x.Zip
+	}
+	return resolved
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler.
+func (x *Address) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	obj, ok := src.(map[string]any)
+	if !ok {
+		return &errs.UnmarshalError{Err: fmt.Errorf("cannot decode %T into gentest.Address", src)}
+	}
+	if m.Strict {
+		known := []string{"street", "zip"}
+		if err := m.CheckFields(obj, known, nil); err != nil {
+			return err
+		}
+	}
+	if v, ok := obj["street"]; ok {
+		if err := m.UnmarshalField("street", v, &x.Street); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Street)
+	}
+	if v, ok := obj["zip"]; ok {
+		if err := m.UnmarshalField("zip", v, &x.Zip); err != nil {
+			return err
+		}
+	} else {
+		m.ResetField(&x.Zip)
+	}
+	return nil
+}
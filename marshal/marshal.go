@@ -1,23 +1,134 @@
 package marshal
 
 import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/errs"
 	"reflect"
-	"strings"
+	"strconv"
 )
 
+// Codec converts Go values to and from the values sent to and received
+// from SurrealDB according to the db struct tags.
+type Codec struct {
+	// FallbackTag is the struct tag used for fields without a db tag.
+	FallbackTag string
+	// Strict makes Unmarshal fail on fields which are not present in the
+	// destination struct, on missing fields with the required option and on
+	// lossy conversions, e.g. of 1.5 into an int.
+	Strict bool
+	// Merge makes Unmarshal keep the current value of the destination if
+	// the source is NULL or a zero value such as false, 0 or "", and keep the
+	// struct fields which are missing from the source. By default the
+	// destination is overwritten, NULL and missing fields resetting it to its
+	// zero value.
+	Merge bool
+}
+
+// Marshaler marshals and unmarshals values like a Codec whose fallback tag is
+// the value of the Marshaler, e.g. Marshaler("json").
 type Marshaler string
 
+// Codec returns a Codec with the fallback tag of m.
+func (m Marshaler) Codec() *Codec {
+	return &Codec{FallbackTag: string(m)}
+}
+
+// Marshal is like Codec.Marshal.
 func (m *Marshaler) Marshal(vars map[string]any) map[string]any {
+	return m.Codec().Marshal(vars)
+}
+
+// Unmarshal is like Codec.Unmarshal.
+func (m *Marshaler) Unmarshal(src, dest any) error {
+	return m.Codec().Unmarshal(src, dest)
+}
+
+// SurrealMarshaler is implemented by types which can resolve themselves into
+// a SurrealDB object without reflection. Implementations are usually generated
+// by cmd/surgo-gen and follow the same tag rules as Codec.
+type SurrealMarshaler interface {
+	MarshalSurreal() map[string]any
+}
+
+// SurrealUnmarshaler is the decoding counterpart of SurrealMarshaler.
+type SurrealUnmarshaler interface {
+	UnmarshalSurreal(m *Codec, src any) error
+}
+
+// Prefixed is a SurrealQL datetime, bytes or uuid literal with its type
+// prefix, e.g. d"2020-01-01T00:00:00Z", as returned by Marshal. It is sent
+// like any other string, the type only tells these literals apart from
+// strings which look the same, e.g. when writing literals with the sql
+// package.
+type Prefixed string
+
+// PrefixedMarshaler is implemented by types which are marshaled as a prefixed
+// literal, such as surgo.UUID.
+type PrefixedMarshaler interface {
+	MarshalPrefixed() Prefixed
+}
+
+// Optional is implemented by types which tell an absent value (NONE) apart
+// from NULL, such as surgo.Option. Absent values are left out of objects and
+// the nil value of a present Optional is marshaled as NULL. If the type also
+// implements SurrealUnmarshaler, it is called for NULL sources as well.
+type Optional interface {
+	SurrealOption() (v any, present bool)
+}
+
+func (m *Codec) Marshal(vars map[string]any) map[string]any {
 	for k, v := range vars {
+		if isAbsent(v) {
+			delete(vars, k)
+			continue
+		}
 		vars[k] = m.marshal(v)
 	}
 
 	return vars
 }
 
-func (m *Marshaler) marshal(v any) any {
-	if isTime(v) {
+// MarshalVars marshals the vars of a query. v is either a map with string keys
+// or a struct, whose fields become the vars according to the struct tags. A
+// nil v results in no vars.
+func (m *Codec) MarshalVars(v any) (map[string]any, error) {
+	if v == nil || isNilPtr(v) {
+		return nil, nil
+	} else if vars, ok := v.(map[string]any); ok {
+		return m.Marshal(vars), nil
+	} else if isStruct(v) || isMap(v) {
+		if vars, ok := m.marshal(v).(map[string]any); ok {
+			return vars, nil
+		}
+	}
+	return nil, errs.ErrMarshal.Withf("vars must be a map or a struct, got %T", v)
+}
+
+func (m *Codec) marshal(v any) any {
+	if v == nil || isNilPtr(v) {
+		return nil
+	} else if o, ok := v.(Optional); ok {
+		// absent values which can't be left out, e.g. in arrays, are NULL
+		v, _ := o.SurrealOption()
+		return m.marshal(v)
+	} else if sm, ok := v.(SurrealMarshaler); ok {
+		return m.Marshal(sm.MarshalSurreal())
+	} else if pm, ok := v.(PrefixedMarshaler); ok {
+		return pm.MarshalPrefixed()
+	} else if isTime(v) {
 		return parseTimes(v)
+	} else if isBigNumber(v) {
+		return parseBigNumber(v)
+	} else if _, ok := v.(json.Marshaler); ok && !isStruct(v) {
+		// types which know their own JSON form, e.g. surgo.Decimal, are
+		// left to the encoder. Structs are resolved by their tags anyway,
+		// their MarshalJSON is usually meant for an API rather than the
+		// database.
+		return v
+	} else if isBytes(v) {
+		return parseBytes(v)
 	} else if isStruct(v) {
 		return m.handleStruct(v)
 	} else if isSlice(v) {
@@ -29,6 +140,20 @@ func (m *Marshaler) marshal(v any) any {
 	}
 }
 
+func isAbsent(x any) bool {
+	o, ok := x.(Optional)
+	if !ok || isNilPtr(x) {
+		return false
+	}
+	_, present := o.SurrealOption()
+	return !present
+}
+
+func isNilPtr(x any) bool {
+	v := reflect.ValueOf(x)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
 func isMap(x any) bool {
 	t := reflect.TypeOf(x)
 	return t.Kind() == reflect.Map || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Map)
@@ -36,7 +161,10 @@ func isMap(x any) bool {
 
 func isSlice(x any) bool {
 	t := reflect.TypeOf(x)
-	return t.Kind() == reflect.Slice || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
 }
 
 func isStruct(x any) bool {
@@ -44,18 +172,45 @@ func isStruct(x any) bool {
 	return t.Kind() == reflect.Struct || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct)
 }
 
-func (m *Marshaler) handleMap(x any) map[string]any {
-	t := reflect.TypeOf(x)
-	v := reflect.ValueOf(x)
+func (m *Codec) handleMap(x any) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(x))
+	if !v.IsValid() || v.IsNil() {
+		return nil
+	} else if vars, ok := v.Interface().(map[string]any); ok {
+		return m.Marshal(vars)
+	}
 
-	if t.Kind() == reflect.Ptr {
-		return m.Marshal(v.Elem().Interface().(map[string]any))
+	resolved := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		if val := iter.Value().Interface(); !isAbsent(val) {
+			resolved[mapKeyString(iter.Key())] = m.marshal(val)
+		}
+	}
+	return resolved
+}
+
+// mapKeyString is the counterpart of the map key conversion of Unmarshal.
+func mapKeyString(key reflect.Value) string {
+	if tm, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if text, err := tm.MarshalText(); err == nil {
+			return string(text)
+		}
 	}
 
-	return m.Marshal(v.Interface().(map[string]any))
+	switch key.Kind() {
+	case reflect.String:
+		return key.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(key.Uint(), 10)
+	default:
+		return fmt.Sprint(key.Interface())
+	}
 }
 
-func (m *Marshaler) handleSlice(x any) []any {
+func (m *Codec) handleSlice(x any) []any {
 	t := reflect.TypeOf(x)
 	v := reflect.ValueOf(x)
 
@@ -72,7 +227,7 @@ func (m *Marshaler) handleSlice(x any) []any {
 	return resolved
 }
 
-func (m *Marshaler) handleStruct(x any) map[string]any {
+func (m *Codec) handleStruct(x any) map[string]any {
 	t := reflect.TypeOf(x)
 	v := reflect.ValueOf(x)
 
@@ -82,29 +237,16 @@ func (m *Marshaler) handleStruct(x any) map[string]any {
 	}
 
 	resolved := make(map[string]any)
-	for i := range t.NumField() {
-		field := t.Field(i)
-		name := field.Name
-		dbTag := field.Tag.Get("db")
-		if dbTag == "" {
-			dbTag = field.Tag.Get(string(*m))
+	for _, f := range m.fields(t) {
+		val, ok := fieldByIndex(v, f.index)
+		if !ok || val.IsZero() && f.opts.has("omitempty") {
+			continue
 		}
 
-		if dbTag != "" {
-			vals := strings.Split(dbTag, ",")
-			if vals[0] == "-" {
-				continue
-			} else {
-				name = vals[0]
-			}
-
-			if v.Field(i).IsZero() && len(vals) > 1 && vals[1] == "omitempty" {
-				continue
-			}
+		if f.opts.has("utc") {
+			val = toUTC(val)
 		}
-
-		val := v.Field(i).Interface()
-		resolved[name] = val
+		resolved[f.name] = val.Interface()
 	}
 
 	return m.Marshal(resolved)
@@ -0,0 +1,240 @@
+package marshal
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestMarshaler_Marshal(t *testing.T) {
+	m := Codec{FallbackTag: "json"}
+
+	t.Run("time marshal", func(t *testing.T) {
+		tm := time.Date(2020, 1, 1, 2, 0, 0, 123456789, time.FixedZone("", 2*60*60))
+		vars := m.Marshal(map[string]any{"time": tm})
+		assert.Equal(t, Prefixed(`d"2020-01-01T02:00:00.123456789+02:00"`), vars["time"])
+	})
+	t.Run("duration marshal", func(t *testing.T) {
+		vars := m.Marshal(map[string]any{"duration": time.Hour + 30*time.Minute})
+		assert.Equal(t, "1h30m", vars["duration"])
+	})
+	t.Run("struct with tags", func(t *testing.T) {
+		type testStruct struct {
+			Test   string `db:"test"`
+			Num    int    `json:"num"`
+			Omit   string `db:",omitempty"`
+			Ignore string `db:"-"`
+			hidden string
+		}
+		vars := m.Marshal(map[string]any{"v": testStruct{Test: "test", Num: 42, Ignore: "x", hidden: "x"}})
+		assert.Equal(t, map[string]any{"test": "test", "num": 42}, vars["v"])
+	})
+	t.Run("time with utc option", func(t *testing.T) {
+		type testStruct struct {
+			Time    time.Time  `db:"time,utc"`
+			TimePtr *time.Time `db:"ptr,omitempty,utc"`
+		}
+		tm := time.Date(2020, 1, 1, 2, 0, 0, 500, time.FixedZone("", 2*60*60))
+		vars := m.Marshal(map[string]any{"v": testStruct{tm, &tm}})
+		assert.Equal(t, map[string]any{
+			"time": Prefixed(`d"2020-01-01T00:00:00.0000005Z"`),
+			"ptr":  Prefixed(`d"2020-01-01T00:00:00.0000005Z"`),
+		}, vars["v"])
+	})
+	t.Run("big number marshal", func(t *testing.T) {
+		i, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+		f, _ := new(big.Float).SetPrec(200).SetString("1234567890.123456789012345678901")
+		vars := m.Marshal(map[string]any{"int": i, "intValue": *i, "float": f, "nil": (*big.Int)(nil)})
+
+		b, err := json.Marshal(vars)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"float":1234567890.123456789012345678901,"int":123456789012345678901234567890,`+
+			`"intValue":123456789012345678901234567890,"nil":null}`, string(b))
+	})
+	t.Run("embedded structs", func(t *testing.T) {
+		type (
+			Base struct {
+				ID   string `db:"id"`
+				Name string `db:"name"`
+			}
+			Audit struct {
+				By string `db:"by"`
+			}
+			Address struct {
+				Street string `db:"street"`
+			}
+			testStruct struct {
+				Base
+				*Audit
+				Tagged  Base    `db:"tagged"`
+				Address Address `db:",inline"`
+				Name    string  `db:"name"`
+			}
+		)
+
+		vars := m.Marshal(map[string]any{
+			"nilPtr": testStruct{Base: Base{ID: "a", Name: "hidden"}, Name: "b"},
+			"v":      testStruct{Base{"a", "hidden"}, &Audit{"c"}, Base{"d", "e"}, Address{"f"}, "b"},
+		})
+		assert.Equal(t, map[string]any{
+			"id":     "a",
+			"tagged": map[string]any{"id": "", "name": ""},
+			"street": "",
+			"name":   "b",
+		}, vars["nilPtr"])
+		assert.Equal(t, map[string]any{
+			"id":     "a",
+			"by":     "c",
+			"tagged": map[string]any{"id": "d", "name": "e"},
+			"street": "f",
+			"name":   "b",
+		}, vars["v"])
+	})
+	t.Run("conflicting embedded fields", func(t *testing.T) {
+		type (
+			A struct {
+				Value  string `db:"value"`
+				Tagged string `db:"tagged"`
+			}
+			B struct {
+				Value  string `db:"value"`
+				Tagged string
+			}
+			testStruct struct {
+				A
+				B
+			}
+		)
+
+		vars := m.Marshal(map[string]any{"v": testStruct{A{"a", "a"}, B{"b", "b"}}})
+		assert.Equal(t, map[string]any{"tagged": "a", "Tagged": "b"}, vars["v"])
+	})
+	t.Run("embedded round trip", func(t *testing.T) {
+		type (
+			Base struct {
+				ID      string    `db:"id"`
+				Created time.Time `db:"created"`
+			}
+			Meta struct {
+				Version int `db:"version"`
+			}
+			testStruct struct {
+				Base
+				*Meta
+				Sleep time.Duration `db:"sleep"`
+				Inner struct {
+					Value string `db:"value"`
+				} `db:",inline"`
+			}
+		)
+
+		in := testStruct{
+			Base:  Base{"a", time.Date(2020, 1, 1, 0, 0, 0, 1, time.UTC)},
+			Meta:  &Meta{Version: 2},
+			Sleep: time.Minute,
+		}
+		in.Inner.Value = "b"
+
+		var out testStruct
+		err := m.Unmarshal(m.Marshal(map[string]any{"v": in})["v"], &out)
+		assert.NoError(t, err)
+		assert.Equal(t, in, out)
+	})
+	t.Run("typed maps and arrays", func(t *testing.T) {
+		type user struct {
+			Name string `db:"name"`
+		}
+		vars := m.Marshal(map[string]any{
+			"users":  map[string]user{"a": {"john"}},
+			"times":  map[int]time.Duration{1: time.Second},
+			"array":  [2]time.Duration{time.Second, time.Minute},
+			"big":    map[*big.Int]bool{big.NewInt(42): true},
+			"nilMap": map[string]string(nil),
+			"nil":    nil,
+		})
+		assert.Equal(t, map[string]any{
+			"users":  map[string]any{"a": map[string]any{"name": "john"}},
+			"times":  map[string]any{"1": "1s"},
+			"array":  []any{"1s", "1m"},
+			"big":    map[string]any{"42": true},
+			"nilMap": map[string]any(nil),
+			"nil":    nil,
+		}, vars)
+	})
+	t.Run("typed map round trip", func(t *testing.T) {
+		in := map[int]map[string]time.Time{1: {"a": time.Date(2020, 1, 1, 0, 0, 0, 5, time.UTC)}}
+
+		var out map[int]map[string]time.Time
+		err := m.Unmarshal(m.Marshal(map[string]any{"v": in})["v"], &out)
+		assert.NoError(t, err)
+		assert.Equal(t, in, out)
+	})
+	t.Run("bytes marshal", func(t *testing.T) {
+		type file struct {
+			Data []byte          `db:"data"`
+			Raw  json.RawMessage `db:"raw"`
+		}
+		vars := m.Marshal(map[string]any{
+			"bytes": []byte("test"),
+			"nil":   []byte(nil),
+			"file":  file{Data: []byte{0xca, 0xfe}, Raw: json.RawMessage(`{"a":1}`)},
+		})
+		assert.Equal(t, map[string]any{
+			"bytes": Prefixed(`b"74657374"`),
+			"nil":   nil,
+			"file":  map[string]any{"data": Prefixed(`b"CAFE"`), "raw": json.RawMessage(`{"a":1}`)},
+		}, vars)
+	})
+	t.Run("bytes round trip", func(t *testing.T) {
+		var out []byte
+		err := m.Unmarshal(m.Marshal(map[string]any{"v": []byte{0, 1, 0xff}})["v"], &out)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0, 1, 0xff}, out)
+	})
+	t.Run("json.Marshaler", func(t *testing.T) {
+		vars := m.Marshal(map[string]any{
+			"struct": jsonStruct{Name: "john", Secret: "hidden"},
+			"ptr":    &jsonStruct{Name: "jane"},
+			"string": jsonString("a"),
+		})
+		assert.Equal(t, map[string]any{
+			"struct": map[string]any{"name": "john", "secret": "hidden"},
+			"ptr":    map[string]any{"name": "jane", "secret": ""},
+			"string": jsonString("a"),
+		}, vars)
+	})
+}
+
+func TestMarshaler(t *testing.T) {
+	type testStruct struct {
+		Name string `json:"name"`
+	}
+	m := Marshaler("json")
+	assert.Equal(t, &Codec{FallbackTag: "json"}, m.Codec())
+
+	vars := m.Marshal(map[string]any{"v": testStruct{Name: "john"}})
+	assert.Equal(t, map[string]any{"v": map[string]any{"name": "john"}}, vars)
+
+	var out testStruct
+	assert.NoError(t, m.Unmarshal(vars["v"], &out))
+	assert.Equal(t, testStruct{Name: "john"}, out)
+}
+
+// jsonStruct has a JSON form for an API which differs from its db tags.
+type jsonStruct struct {
+	Name   string `db:"name"`
+	Secret string `db:"secret"`
+}
+
+func (s jsonStruct) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"username": s.Name})
+}
+
+// jsonString is passed through like surgo.Decimal.
+type jsonString string
+
+func (s jsonString) MarshalJSON() ([]byte, error) {
+	return json.Marshal("json:" + string(s))
+}
@@ -1,27 +1,166 @@
 package marshal
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var units = map[string]time.Duration{
-	"y":  365 * 24 * time.Hour,
-	"w":  7 * 24 * time.Hour,
-	"d":  24 * time.Hour,
-	"h":  time.Hour,
-	"m":  time.Minute,
-	"s":  time.Second,
-	"ms": time.Millisecond,
-	"µs": time.Microsecond,
-	"us": time.Microsecond,
-	"ns": time.Nanosecond,
+// units are the SurrealQL duration units ordered from largest to smallest.
+// "us" is only accepted when parsing, durations are always written with "µs".
+var units = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"y", 365 * 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+	{"µs", time.Microsecond},
+	{"ns", time.Nanosecond},
+}
+
+// FormatDuration returns the canonical SurrealQL representation of d, e.g.
+// 1h30m or 1s500ms. Units are written from largest to smallest with nanosecond
+// precision, zero is written as 0ns and negative durations are prefixed with a
+// minus sign.
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0ns"
+	}
+
+	var b strings.Builder
+	n := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		n = -n
+	}
+
+	for _, unit := range units {
+		if amount := n / uint64(unit.duration); amount > 0 {
+			n -= amount * uint64(unit.duration)
+			b.WriteString(strconv.FormatUint(amount, 10))
+			b.WriteString(unit.name)
+		}
+	}
+	return b.String()
+}
+
+// ParseDuration parses a SurrealQL duration such as 1h30m or 1s500ms. It is
+// the inverse of FormatDuration but also accepts units in any order and the
+// "us" spelling of microseconds.
+func ParseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+
+	var total uint64
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		amount, err := strconv.ParseUint(s[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", orig, err)
+		}
+		s = s[i:]
+
+		unit, ok := unitOf(s)
+		if !ok {
+			return 0, fmt.Errorf("invalid unit in duration %q", orig)
+		}
+		s = s[len(unit):]
+
+		size := uint64(unitDuration(unit))
+		if amount > math.MaxUint64/size || total+amount*size < total {
+			return 0, fmt.Errorf("duration %q overflows", orig)
+		}
+		total += amount * size
+	}
+
+	if neg && total > 1<<63 || !neg && total > 1<<63-1 {
+		return 0, fmt.Errorf("duration %q overflows", orig)
+	} else if neg {
+		return time.Duration(-total), nil
+	}
+	return time.Duration(total), nil
+}
+
+func unitOf(s string) (string, bool) {
+	// units with two characters have to be checked first, so that
+	// ms is not read as m followed by s.
+	for _, name := range []string{"ms", "µs", "us", "ns", "y", "w", "d", "h", "m", "s"} {
+		if strings.HasPrefix(s, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func unitDuration(name string) time.Duration {
+	if name == "us" {
+		return time.Microsecond
+	}
+	for _, unit := range units {
+		if unit.name == name {
+			return unit.duration
+		}
+	}
+	return 0
+}
+
+func isBigNumber(n any) bool {
+	switch n.(type) {
+	case big.Int, *big.Int, big.Float, *big.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseBigNumber returns the exact decimal representation of n as a
+// json.Number, so that it is written as a number literal without ever
+// passing through float64.
+func parseBigNumber(n any) any {
+	switch n := n.(type) {
+	case big.Int:
+		return json.Number(n.String())
+	case *big.Int:
+		if n != nil {
+			return json.Number(n.String())
+		}
+	case big.Float:
+		return json.Number(n.Text('f', -1))
+	case *big.Float:
+		if n != nil {
+			return json.Number(n.Text('f', -1))
+		}
+	}
+	return nil
 }
 
 func isTime(ts any) bool {
 	switch ts.(type) {
-	case time.Time, time.Duration:
+	case time.Time, time.Duration, *time.Time, *time.Duration:
 		return true
 	default:
 		return false
@@ -31,30 +170,119 @@ func isTime(ts any) bool {
 func parseTimes(ts any) any {
 	switch ts.(type) {
 	case time.Time:
-		return ts.(time.Time).Format("d\"2006-01-02T15:04:05Z07:00\"")
+		return Prefixed(FormatDatetime(ts.(time.Time)))
 	case time.Duration:
-		var result string
-		d := ts.(time.Duration)
-		for unit, duration := range units {
-			if d >= duration {
-				amount := d / duration
-				d -= amount * duration
-				result += fmt.Sprintf("%d%s", amount, unit)
-			}
-		}
-		return result
+		return FormatDuration(ts.(time.Duration))
+	case *time.Time:
+		if t := ts.(*time.Time); t != nil {
+			return Prefixed(FormatDatetime(*t))
+		}
+		return nil
+	case *time.Duration:
+		if d := ts.(*time.Duration); d != nil {
+			return FormatDuration(*d)
+		}
+		return nil
 	default:
 		return ts
 	}
 }
 
-func (m *Marshaler) tagOf(field reflect.StructField) string {
-	dbTag := field.Tag.Get("db")
-	if dbTag == "" {
-		dbTag = field.Tag.Get(string(*m))
+// datetimeLayouts are the layouts in which SurrealDB may return a datetime.
+// RFC3339Nano also accepts datetimes without fractional seconds. Datetimes
+// without a zone are interpreted as UTC.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// FormatDatetime returns the SurrealQL datetime literal of t with nanosecond
+// precision, e.g. d"2020-01-01T00:00:00.123456789Z".
+func FormatDatetime(t time.Time) string {
+	return `d"` + t.Format(time.RFC3339Nano) + `"`
+}
+
+// ParseDatetime parses a datetime as returned by SurrealDB. Both plain strings
+// and SurrealQL datetime literals (d"..." or d'...') are accepted.
+func ParseDatetime(s string) (time.Time, error) {
+	if len(s) > 3 && s[0] == 'd' && (s[1] == '"' || s[1] == '\'') && s[len(s)-1] == s[1] {
+		s = s[2 : len(s)-1]
+	}
+
+	var err error
+	for _, layout := range datetimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// FormatBytes returns the SurrealQL bytes literal of b, e.g. b"74657374".
+func FormatBytes(b []byte) string {
+	return `b"` + strings.ToUpper(hex.EncodeToString(b)) + `"`
+}
+
+// ParseBytes parses a SurrealQL bytes literal (b"..." or b'...') containing
+// hexadecimal digits.
+func ParseBytes(s string) ([]byte, error) {
+	if !isBytesLiteral(s) {
+		return nil, fmt.Errorf("invalid bytes literal %q", s)
+	}
+	return hex.DecodeString(s[2 : len(s)-1])
+}
+
+func isBytesLiteral(s string) bool {
+	return len(s) > 2 && s[0] == 'b' && (s[1] == '"' || s[1] == '\'') && s[len(s)-1] == s[1]
+}
+
+func isBytes(b any) bool {
+	t := reflect.TypeOf(b)
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func parseBytes(b any) any {
+	v := reflect.ValueOf(b)
+	if v.IsNil() {
+		return nil
 	}
-	if dbTag == "" || dbTag[0] == ',' {
-		dbTag = field.Name + dbTag
+	return Prefixed(FormatBytes(v.Bytes()))
+}
+
+// tagOptions are the comma separated options following the name in a struct tag.
+type tagOptions []string
+
+func (o tagOptions) has(opt string) bool {
+	return slices.Contains(o, opt)
+}
+
+// parseTag returns the name and options of the given field and whether the
+// name was set explicitly in the db or fallback tag.
+func (m *Codec) parseTag(field reflect.StructField) (string, tagOptions, bool) {
+	tag := field.Tag.Get("db")
+	if tag == "" {
+		tag = field.Tag.Get(m.FallbackTag)
+	}
+
+	vals := strings.Split(tag, ",")
+	if vals[0] == "" {
+		return field.Name, vals[1:], false
+	}
+	return vals[0], vals[1:], true
+}
+
+// toUTC converts v to UTC if it is a time.Time or a non-nil *time.Time.
+func toUTC(v reflect.Value) reflect.Value {
+	switch t := v.Interface().(type) {
+	case time.Time:
+		return reflect.ValueOf(t.UTC())
+	case *time.Time:
+		if t != nil {
+			utc := t.UTC()
+			return reflect.ValueOf(&utc)
+		}
 	}
-	return dbTag
+	return v
 }
@@ -0,0 +1,158 @@
+package marshal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                   "0ns",
+		time.Nanosecond:                     "1ns",
+		time.Second + 500*time.Millisecond:  "1s500ms",
+		8*time.Hour + 30*time.Minute:        "8h30m",
+		90 * time.Minute:                    "1h30m",
+		-90 * time.Minute:                   "-1h30m",
+		366*24*time.Hour + time.Microsecond: "1y1d1µs",
+		15 * 24 * time.Hour:                 "2w1d",
+		math.MaxInt64:                       "292y24w3d23h47m16s854ms775µs807ns",
+		math.MinInt64:                       "-292y24w3d23h47m16s854ms775µs808ns",
+	}
+
+	for d, want := range tests {
+		assert.Equal(t, want, FormatDuration(d))
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	t.Run("valid durations", func(t *testing.T) {
+		tests := map[string]time.Duration{
+			"0ns":     0,
+			"1s":      time.Second,
+			"1s500ms": time.Second + 500*time.Millisecond,
+			"30m8h":   8*time.Hour + 30*time.Minute,
+			"1us":     time.Microsecond,
+			"1µs":     time.Microsecond,
+			"-1h30m":  -90 * time.Minute,
+			"1y":      365 * 24 * time.Hour,
+		}
+
+		for s, want := range tests {
+			d, err := ParseDuration(s)
+			assert.NoError(t, err, s)
+			assert.Equal(t, want, d, s)
+		}
+	})
+	t.Run("invalid durations", func(t *testing.T) {
+		for _, s := range []string{"", "-", "1", "h", "1x", "1h 30m", "1h30", "300y", "18446744073709551616ns"} {
+			_, err := ParseDuration(s)
+			assert.Error(t, err, s)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		roundTrip := func(n int64) bool {
+			d, err := ParseDuration(FormatDuration(time.Duration(n)))
+			return err == nil && d == time.Duration(n)
+		}
+
+		assert.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 10000}))
+		for _, n := range []int64{0, 1, -1, math.MaxInt64, math.MinInt64} {
+			assert.True(t, roundTrip(n), n)
+		}
+	})
+	t.Run("format is canonical", func(t *testing.T) {
+		tests := map[string]string{
+			"30m8h":  "8h30m",
+			"90m":    "1h30m",
+			"1000ms": "1s",
+			"1us":    "1µs",
+			"7d":     "1w",
+			"365d":   "1y",
+			"-60s":   "-1m",
+			"0s":     "0ns",
+		}
+
+		for s, want := range tests {
+			d, err := ParseDuration(s)
+			assert.NoError(t, err, s)
+			assert.Equal(t, want, FormatDuration(d), s)
+		}
+	})
+}
+
+func TestFormatDatetime(t *testing.T) {
+	tm := time.Date(2020, 1, 1, 12, 30, 0, 123456789, time.FixedZone("", 2*60*60))
+	assert.Equal(t, `d"2020-01-01T12:30:00.123456789+02:00"`, FormatDatetime(tm))
+	assert.Equal(t, `d"2020-01-01T10:30:00.123456789Z"`, FormatDatetime(tm.UTC()))
+	assert.Equal(t, `d"2020-01-01T00:00:00Z"`, FormatDatetime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestParseDatetime(t *testing.T) {
+	t.Run("valid datetimes", func(t *testing.T) {
+		tests := map[string]time.Time{
+			"2020-01-01T00:00:00Z":                   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			"2020-01-01T00:00:00.000001Z":            time.Date(2020, 1, 1, 0, 0, 0, 1000, time.UTC),
+			"2020-01-01T00:00:00.123456789Z":         time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC),
+			"2020-01-01T02:00:00.5+02:00":            time.Date(2020, 1, 1, 0, 0, 0, 5e8, time.UTC),
+			"2020-01-01T00:00:00.123":                time.Date(2020, 1, 1, 0, 0, 0, 123e6, time.UTC),
+			"2020-01-01":                             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			`d"2020-01-01T00:00:00.123456789Z"`:      time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC),
+			`d'2020-01-01T00:00:00.123456789-05:00'`: time.Date(2020, 1, 1, 5, 0, 0, 123456789, time.UTC),
+		}
+
+		for s, want := range tests {
+			tm, err := ParseDatetime(s)
+			assert.NoError(t, err, s)
+			assert.True(t, want.Equal(tm), "%s: %v != %v", s, want, tm)
+		}
+	})
+	t.Run("invalid datetimes", func(t *testing.T) {
+		for _, s := range []string{"", "d\"\"", "2020-13-01", "yesterday", `d"2020-01-01T00:00:00Z'`} {
+			_, err := ParseDatetime(s)
+			assert.Error(t, err, s)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		roundTrip := func(sec int64, nsec int32, offset int16) bool {
+			zone := time.FixedZone("", int(offset)%(18*60)*60)
+			tm := time.Unix(sec%(1<<35), int64(nsec)%1e9).In(zone)
+			parsed, err := ParseDatetime(FormatDatetime(tm))
+			return err == nil && parsed.Equal(tm)
+		}
+
+		assert.NoError(t, quick.Check(roundTrip, nil))
+	})
+}
+
+func TestParseBytes(t *testing.T) {
+	t.Run("valid bytes", func(t *testing.T) {
+		tests := map[string][]byte{
+			`b""`:         {},
+			`b"74657374"`: []byte("test"),
+			`b'CAFE'`:     {0xca, 0xfe},
+			`b"cafe"`:     {0xca, 0xfe},
+		}
+		for s, want := range tests {
+			b, err := ParseBytes(s)
+			assert.NoError(t, err, s)
+			assert.Equal(t, want, b, s)
+		}
+	})
+	t.Run("invalid bytes", func(t *testing.T) {
+		for _, s := range []string{"", "cafe", `b"caf"`, `b"cafe'`, `"cafe"`} {
+			_, err := ParseBytes(s)
+			assert.Error(t, err, s)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		roundTrip := func(b []byte) bool {
+			parsed, err := ParseBytes(FormatBytes(b))
+			return err == nil && string(parsed) == string(b)
+		}
+
+		assert.NoError(t, quick.Check(roundTrip, nil))
+	})
+}
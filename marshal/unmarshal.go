@@ -1,39 +1,107 @@
 package marshal
 
 import (
+	"encoding"
+	"encoding/json"
+	"fmt"
 	"github.com/NoBypass/surgo/v2/errs"
+	"maps"
+	"math"
 	"reflect"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func (m *Marshaler) Unmarshal(src, dest any) (err error) {
+// Unmarshal decodes src, a value as returned by SurrealDB, into dest which
+// must be a non-nil pointer. Errors which occur while decoding are of type
+// *errs.UnmarshalError and carry the path to the offending value.
+func (m *Codec) Unmarshal(src, dest any) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = errs.ErrUnmarshal.Withf("type mismatch: %v", r)
+			err = decodeErrorf("type mismatch: %v", r)
 		}
 	}()
 	destVal := reflect.ValueOf(dest)
 
 	if destVal.Kind() != reflect.Ptr || destVal.IsNil() {
 		return errs.ErrUnmarshal.Withf("dest must be a non-nil pointer")
-	} else if src == nil {
-		return nil
 	}
 
 	return m.unmarshal(reflect.ValueOf(src), destVal.Elem())
 }
 
-func (m *Marshaler) unmarshal(src, dest reflect.Value) error {
-	if src.IsZero() {
-		return nil
+// UnmarshalField is like Unmarshal but prefixes the path of a returned error
+// with the given object key. It is used by generated SurrealUnmarshaler
+// implementations.
+func (m *Codec) UnmarshalField(key string, src, dest any) error {
+	if err := m.Unmarshal(src, dest); err != nil {
+		return errorAt(err, key)
+	}
+	return nil
+}
+
+// ResetField sets dest, a pointer to the field of a key which is missing from
+// the source, to its zero value unless m.Merge is set. It is used by generated
+// SurrealUnmarshaler implementations.
+func (m *Codec) ResetField(dest any) {
+	if !m.Merge {
+		reflect.ValueOf(dest).Elem().SetZero()
+	}
+}
+
+// CheckFields returns an error for the first key of obj which is not known
+// and the first required key which is missing in obj. It is used by generated
+// SurrealUnmarshaler implementations in strict mode.
+func (m *Codec) CheckFields(obj map[string]any, known, required []string) error {
+	keys := slices.Sorted(maps.Keys(obj))
+	for _, key := range keys {
+		if !slices.Contains(known, key) {
+			return errorAt(decodeErrorf("unknown field"), key)
+		}
+	}
+	for _, key := range required {
+		if _, ok := obj[key]; !ok {
+			return errorAt(decodeErrorf("missing required field"), key)
+		}
+	}
+	return nil
+}
+
+func (m *Codec) unmarshal(src, dest reflect.Value) error {
+	if dest.CanAddr() {
+		if u, ok := dest.Addr().Interface().(optionalUnmarshaler); ok {
+			var v any
+			if src.IsValid() {
+				v = src.Interface()
+			}
+			return u.UnmarshalSurreal(m, v)
+		}
 	}
 	if src.Kind() == reflect.Interface {
 		src = src.Elem()
 	}
-	if srcType, destType := src.Type(), dest.Type(); srcType != destType && srcType.ConvertibleTo(destType) {
+	if isNull(src) {
+		if !m.Merge {
+			dest.Set(reflect.Zero(dest.Type()))
+		}
+		return nil
+	} else if m.Merge && isZero(src) {
+		return nil
+	}
+	if dest.CanAddr() {
+		switch u := dest.Addr().Interface().(type) {
+		case SurrealUnmarshaler:
+			return u.UnmarshalSurreal(m, src.Interface())
+		case *time.Time:
+			// time.Time implements encoding.TextUnmarshaler but only
+			// accepts RFC 3339, so it is handled by timeDecoder instead.
+		case encoding.TextUnmarshaler:
+			return m.textDecoder(src, u)
+		}
+	}
+	if srcType, destType := src.Type(), dest.Type(); srcType != destType && srcType.ConvertibleTo(destType) && m.convertible(srcType, destType) {
 		src = src.Convert(destType)
 	}
 
@@ -50,7 +118,12 @@ func (m *Marshaler) unmarshal(src, dest reflect.Value) error {
 		}
 		return m.numberDecoder(src, dest)
 	case reflect.Slice:
+		if dest.Type().Elem().Kind() == reflect.Uint8 {
+			return m.bytesDecoder(src, dest)
+		}
 		return m.sliceDecoder(src, dest)
+	case reflect.Array:
+		return m.arrayDecoder(src, dest)
 	case reflect.Interface:
 		return m.interfaceDecoder(src, dest)
 	case reflect.Map:
@@ -63,119 +136,407 @@ func (m *Marshaler) unmarshal(src, dest reflect.Value) error {
 	case reflect.Ptr:
 		return m.pointerDecoder(src, dest)
 	default:
-		return errs.ErrUnmarshal.Withf("cannot unmarshal %s", dest.Type())
+		return decodeErrorf("cannot decode into %s", dest.Type())
 	}
 }
 
-func (m *Marshaler) pointerDecoder(src, dest reflect.Value) error {
+// isNull reports whether v is NULL, i.e. invalid or a nil value.
+func isNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// isZero reports whether v is a zero value. Numbers which are still in their
+// textual form are zero if they equal 0.
+func isZero(v reflect.Value) bool {
+	if n, ok := v.Interface().(json.Number); ok {
+		f, err := n.Float64()
+		return err == nil && f == 0
+	}
+	return v.IsZero()
+}
+
+// optionalUnmarshaler is an Optional which decodes NULL sources itself.
+type optionalUnmarshaler interface {
+	Optional
+	SurrealUnmarshaler
+}
+
+// convertible reports whether src may be converted to dest before decoding.
+// In strict mode only types of the same kind are converted, other numbers are
+// left to numberDecoder which rejects lossy conversions. Strings are never
+// converted to byte slices, as they may contain a bytes literal which is
+// decoded by bytesDecoder.
+func (m *Codec) convertible(src, dest reflect.Type) bool {
+	if src.Kind() == reflect.String && dest.Kind() == reflect.Slice && dest.Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	return !m.Strict || src.Kind() == dest.Kind()
+}
+
+func (m *Codec) pointerDecoder(src, dest reflect.Value) error {
 	if dest.IsNil() {
 		dest.Set(reflect.New(dest.Type().Elem()))
 	}
 	return m.unmarshal(src, dest.Elem())
 }
 
-func (m *Marshaler) simpleValueDecoder(src, dest reflect.Value) error {
+func (m *Codec) simpleValueDecoder(src, dest reflect.Value) error {
+	if !src.Type().AssignableTo(dest.Type()) {
+		return mismatch(src, dest.Type())
+	}
 	dest.Set(src)
 	return nil
 }
 
-func (m *Marshaler) numberDecoder(src, dest reflect.Value) error {
+func (m *Codec) numberDecoder(src, dest reflect.Value) error {
+	if n, ok := src.Interface().(json.Number); ok {
+		return m.jsonNumberDecoder(n, dest)
+	}
+
+	if !isNumber(src.Kind()) {
+		return mismatch(src, dest.Type())
+	} else if !fitsNumber(src, dest, !m.Strict) {
+		return decodeErrorf("cannot decode %v into %s without loss", src, dest.Type())
+	}
+
+	dest.Set(src.Convert(dest.Type()))
+	return nil
+}
+
+func (m *Codec) jsonNumberDecoder(n json.Number, dest reflect.Value) error {
+	var err error
+	var src any
 	switch dest.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		dest.SetInt(src.Convert(dest.Type()).Int())
+		src, err = strconv.ParseInt(string(n), 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		src, err = strconv.ParseUint(string(n), 10, 64)
+	}
+
+	// numbers which are not integers or do not fit into 64 bits are decoded
+	// through float64, numberDecoder decides whether that is lossy.
+	if src == nil || err != nil {
+		if src, err = strconv.ParseFloat(string(n), 64); err != nil {
+			return decodeErrorf("cannot decode number %s into %s: %w", n, dest.Type(), err)
+		}
+	}
+	return m.numberDecoder(reflect.ValueOf(src), dest)
+}
+
+// textDecoder unmarshals strings and numbers into types implementing
+// encoding.TextUnmarshaler, such as *big.Int and *big.Float.
+func (m *Codec) textDecoder(src reflect.Value, dest encoding.TextUnmarshaler) error {
+	var text string
+	switch src.Kind() {
+	case reflect.String:
+		text = src.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		text = strconv.FormatInt(src.Int(), 10)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		dest.SetUint(src.Convert(dest.Type()).Uint())
+		text = strconv.FormatUint(src.Uint(), 10)
 	case reflect.Float32, reflect.Float64:
-		dest.SetFloat(src.Convert(dest.Type()).Float())
+		text = strconv.FormatFloat(src.Float(), 'f', -1, src.Type().Bits())
 	default:
-		return errs.ErrUnmarshal.Withf("unsupported number type: %s", dest.Type())
+		return decodeErrorf("cannot decode %s into %T", src.Type(), dest)
+	}
+
+	if err := dest.UnmarshalText([]byte(text)); err != nil {
+		return decodeErrorf("cannot decode %q into %T: %w", text, dest, err)
 	}
 	return nil
 }
 
-func (m *Marshaler) timeDecoder(src, dest reflect.Value) error {
-	t, err := time.Parse(time.RFC3339, src.String())
+func (m *Codec) timeDecoder(src, dest reflect.Value) error {
+	if t, ok := src.Interface().(time.Time); ok {
+		dest.Set(reflect.ValueOf(t))
+		return nil
+	} else if src.Kind() != reflect.String {
+		return mismatch(src, dest.Type())
+	}
+
+	t, err := ParseDatetime(src.String())
 	if err != nil {
-		return errs.ErrUnmarshal.Withf("cannot parse time: %w", err)
+		return decodeErrorf("cannot parse time: %w", err)
 	}
 
 	dest.Set(reflect.ValueOf(t))
 	return nil
 }
 
-func (m *Marshaler) durationDecoder(src, dest reflect.Value) error {
-	re := regexp.MustCompile(`(\d+)(y|w|d|h|ms|m|s|µs|us|ns)`)
-	matches := re.FindAllStringSubmatch(src.String(), -1)
-
-	var duration time.Duration
-	for _, match := range matches {
-		value, err := strconv.Atoi(match[1])
-		if err != nil {
-			return errs.ErrUnmarshal.Withf("cannot parse duration: %w", err)
-		}
-		unit := match[2]
+func (m *Codec) durationDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.String {
+		return mismatch(src, dest.Type())
+	}
 
-		duration += time.Duration(value) * units[unit]
+	d, err := ParseDuration(src.String())
+	if err != nil {
+		return decodeErrorf("cannot parse duration: %w", err)
 	}
 
-	dest.Set(reflect.ValueOf(duration))
+	dest.Set(reflect.ValueOf(d))
 	return nil
 }
 
-func (m *Marshaler) sliceDecoder(src, dest reflect.Value) error {
+func (m *Codec) sliceDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		return mismatch(src, dest.Type())
+	}
+
 	slice := reflect.MakeSlice(dest.Type(), src.Len(), src.Len())
 	for i := 0; i < src.Len(); i++ {
 		if err := m.unmarshal(src.Index(i), slice.Index(i)); err != nil {
-			return errs.ErrUnmarshal.Withf("cannot unmarshal slice: %w", err)
+			return errorAt(err, "["+strconv.Itoa(i)+"]")
 		}
 	}
 	dest.Set(slice)
 	return nil
 }
 
-func (m *Marshaler) interfaceDecoder(src, dest reflect.Value) error {
+// bytesDecoder decodes a bytes literal, a plain string or an array of numbers
+// into a byte slice.
+func (m *Codec) bytesDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.String {
+		return m.sliceDecoder(src, dest)
+	}
+
+	b := []byte(src.String())
+	if isBytesLiteral(src.String()) {
+		var err error
+		if b, err = ParseBytes(src.String()); err != nil {
+			return decodeErrorf("cannot decode %q into %s: %w", src.String(), dest.Type(), err)
+		}
+	}
+	dest.Set(reflect.ValueOf(b).Convert(dest.Type()))
+	return nil
+}
+
+func (m *Codec) interfaceDecoder(src, dest reflect.Value) error {
 	if src.Type().AssignableTo(dest.Type()) {
 		dest.Set(src)
 		return nil
 	}
-	return errs.ErrUnmarshal.Withf("cannot unmarshal %s to %s", src.Type(), dest.Type())
+	return mismatch(src, dest.Type())
 }
 
-func (m *Marshaler) mapDecoder(src, dest reflect.Value) error {
-	dest.Set(reflect.MakeMap(dest.Type()))
-	for _, key := range src.MapKeys() {
-		value := src.MapIndex(key)
-		dest.SetMapIndex(key, value)
+// arrayDecoder decodes a slice into a fixed-size array. Missing elements are
+// set to their zero value, surplus elements are an error in strict mode and
+// ignored otherwise.
+func (m *Codec) arrayDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		return mismatch(src, dest.Type())
+	} else if m.Strict && src.Len() > dest.Len() {
+		return decodeErrorf("cannot decode %d elements into %s", src.Len(), dest.Type())
 	}
+
+	array := reflect.New(dest.Type()).Elem()
+	for i := 0; i < min(src.Len(), dest.Len()); i++ {
+		if err := m.unmarshal(src.Index(i), array.Index(i)); err != nil {
+			return errorAt(err, "["+strconv.Itoa(i)+"]")
+		}
+	}
+	dest.Set(array)
 	return nil
 }
 
-func (m *Marshaler) structDecoder(src, dest reflect.Value) error {
-	for i := 0; i < dest.NumField(); i++ {
-		field := dest.Type().Field(i)
-		tag := strings.Split(m.tagOf(field), ",")[0]
+func (m *Codec) mapDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.Map {
+		return mismatch(src, dest.Type())
+	}
+
+	keys := src.MapKeys()
+	if src.Type().Key().Kind() == reflect.String {
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+	}
 
-		fieldVal := dest.Field(i)
-		if !fieldVal.CanSet() {
-			continue
+	destType := dest.Type()
+	result := reflect.MakeMapWithSize(destType, len(keys))
+	for _, key := range keys {
+		destKey, err := m.mapKey(key, destType.Key())
+		if err != nil {
+			return err
 		}
 
-		if field.Anonymous {
-			if err := m.unmarshal(src, fieldVal); err != nil {
-				return err
+		value := reflect.New(destType.Elem()).Elem()
+		if err := m.unmarshal(src.MapIndex(key), value); err != nil {
+			return errorAt(err, fmt.Sprint(key.Interface()))
+		}
+		result.SetMapIndex(destKey, value)
+	}
+	dest.Set(result)
+	return nil
+}
+
+// mapKey converts the key of a source map into the key type of the
+// destination map. Keys are converted with encoding.TextUnmarshaler if the
+// key type implements it, otherwise string and integer keys are supported.
+func (m *Codec) mapKey(key reflect.Value, keyType reflect.Type) (reflect.Value, error) {
+	if key.Kind() == reflect.Interface {
+		key = key.Elem()
+	}
+
+	destKey := reflect.New(keyType)
+	target := destKey
+	if keyType.Kind() == reflect.Ptr {
+		target = reflect.New(keyType.Elem())
+		destKey.Elem().Set(target)
+	}
+
+	if u, ok := target.Interface().(encoding.TextUnmarshaler); ok && key.Kind() == reflect.String {
+		if err := u.UnmarshalText([]byte(key.String())); err != nil {
+			return reflect.Value{}, decodeErrorf("cannot decode map key %q into %s: %w", key, keyType, err)
+		}
+		return destKey.Elem(), nil
+	} else if key.Kind() == keyType.Kind() {
+		return key.Convert(keyType), nil
+	} else if key.Kind() != reflect.String {
+		return reflect.Value{}, decodeErrorf("cannot decode map key of type %s into %s", key.Type(), keyType)
+	}
+
+	var err error
+	switch keyType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(key.String(), 10, keyType.Bits()); err == nil {
+			destKey.Elem().SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(key.String(), 10, keyType.Bits()); err == nil {
+			destKey.Elem().SetUint(u)
+		}
+	default:
+		return reflect.Value{}, decodeErrorf("cannot decode map key %q into %s", key, keyType)
+	}
+
+	if err != nil {
+		return reflect.Value{}, decodeErrorf("cannot decode map key %q into %s: %w", key, keyType, err)
+	}
+	return destKey.Elem(), nil
+}
+
+func (m *Codec) structDecoder(src, dest reflect.Value) error {
+	if src.Kind() != reflect.Map || src.Type().Key().Kind() != reflect.String {
+		return mismatch(src, dest.Type())
+	}
+
+	fields := m.fields(dest.Type())
+	if m.Strict {
+		keys := make([]string, 0, src.Len())
+		for _, key := range src.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			if !slices.ContainsFunc(fields, func(f field) bool { return f.name == key }) {
+				return errorAt(decodeErrorf("unknown field"), key)
 			}
-			continue
 		}
+	}
 
-		mapVal := src.MapIndex(reflect.ValueOf(tag))
+	for _, f := range fields {
+		mapVal := src.MapIndex(reflect.ValueOf(f.name).Convert(src.Type().Key()))
 		if !mapVal.IsValid() {
+			if m.Strict && f.opts.has("required") {
+				return errorAt(decodeErrorf("missing required field"), f.name)
+			}
+			if !m.Merge {
+				// fields behind a nil embedded pointer are zero already
+				if fieldVal, ok := fieldByIndex(dest, f.index); ok {
+					fieldVal.Set(reflect.Zero(fieldVal.Type()))
+				}
+			}
 			continue
 		}
 
+		fieldVal := fieldByIndexAlloc(dest, f.index)
 		if err := m.unmarshal(mapVal, fieldVal); err != nil {
-			return err
+			return errorAt(err, f.name)
+		}
+		if f.opts.has("utc") {
+			fieldVal.Set(toUTC(fieldVal))
 		}
 	}
 
 	return nil
 }
+
+// decodeErrorf returns an *errs.UnmarshalError without a path. The path is
+// added by errorAt while the error is returned to the caller.
+func decodeErrorf(format string, args ...any) error {
+	return &errs.UnmarshalError{Err: fmt.Errorf(format, args...)}
+}
+
+func mismatch(src reflect.Value, dest reflect.Type) error {
+	return decodeErrorf("cannot decode %s into %s", src.Type(), dest)
+}
+
+// errorAt prefixes the path of err with segment, which is either an object
+// key or an index such as [3].
+func errorAt(err error, segment string) error {
+	ue, ok := err.(*errs.UnmarshalError)
+	if !ok {
+		return &errs.UnmarshalError{Path: segment, Err: err}
+	}
+
+	switch {
+	case ue.Path == "":
+		ue.Path = segment
+	case ue.Path[0] == '[':
+		ue.Path = segment + ue.Path
+	default:
+		ue.Path = segment + "." + ue.Path
+	}
+	return ue
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+// fitsNumber reports whether the number src can be stored in dest without
+// losing its value. If truncate is set, the fractional part of a float may be
+// dropped. Rounding of floats to float32 is never considered a loss.
+func fitsNumber(src, dest reflect.Value, truncate bool) bool {
+	switch dest.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case src.CanInt():
+			return !dest.OverflowInt(src.Int())
+		case src.CanUint():
+			return src.Uint() <= math.MaxInt64 && !dest.OverflowInt(int64(src.Uint()))
+		default:
+			f := src.Float()
+			if f != math.Trunc(f) && !truncate {
+				return false
+			}
+			f = math.Trunc(f)
+			return f >= math.MinInt64 && f < math.MaxInt64 && !dest.OverflowInt(int64(f))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch {
+		case src.CanInt():
+			return src.Int() >= 0 && !dest.OverflowUint(uint64(src.Int()))
+		case src.CanUint():
+			return !dest.OverflowUint(src.Uint())
+		default:
+			f := src.Float()
+			if f != math.Trunc(f) && !truncate {
+				return false
+			}
+			f = math.Trunc(f)
+			return f >= 0 && f < math.MaxUint64 && !dest.OverflowUint(uint64(f))
+		}
+	default:
+		return !src.CanFloat() || !dest.OverflowFloat(src.Float())
+	}
+}
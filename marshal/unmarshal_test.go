@@ -1,13 +1,18 @@
 package marshal
 
 import (
+	"encoding/json"
+	"github.com/NoBypass/surgo/v2/errs"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"math/big"
+	"strconv"
 	"testing"
 	"time"
 )
 
 func TestMarshaler_Unmarshal(t *testing.T) {
-	m := Marshaler("json")
+	m := Codec{FallbackTag: "json"}
 
 	t.Run("simple bool unmarshal", func(t *testing.T) {
 		var b bool
@@ -27,6 +32,35 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), tm)
 	})
+	t.Run("time with nanoseconds and zone unmarshal", func(t *testing.T) {
+		var tm time.Time
+		err := m.Unmarshal("2020-01-01T02:00:00.123456789+02:00", &tm)
+		assert.NoError(t, err)
+		assert.True(t, time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC).Equal(tm))
+		_, offset := tm.Zone()
+		assert.Equal(t, 2*60*60, offset)
+	})
+	t.Run("time to time unmarshal", func(t *testing.T) {
+		var tm time.Time
+		src := time.Date(2020, 1, 1, 0, 0, 0, 1, time.UTC)
+		err := m.Unmarshal(src, &tm)
+		assert.NoError(t, err)
+		assert.Equal(t, src, tm)
+	})
+	t.Run("time with utc option", func(t *testing.T) {
+		type testStruct struct {
+			Time    time.Time  `db:"time,utc"`
+			TimePtr *time.Time `db:"ptr,utc"`
+		}
+		var s testStruct
+		err := m.Unmarshal(map[string]any{
+			"time": "2020-01-01T02:00:00.5+02:00",
+			"ptr":  "2020-01-01T02:00:00.5+02:00",
+		}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 5e8, time.UTC), s.Time)
+		assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 5e8, time.UTC), *s.TimePtr)
+	})
 	t.Run("simple duration unmarshal", func(t *testing.T) {
 		var d time.Duration
 		err := m.Unmarshal("1s", &d)
@@ -49,6 +83,46 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 			}
 		}
 	})
+	t.Run("json number unmarshal", func(t *testing.T) {
+		var i int64
+		err := m.Unmarshal(json.Number("9007199254740993"), &i)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(9007199254740993), i)
+
+		var u uint64
+		err = m.Unmarshal(json.Number("18446744073709551615"), &u)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(math.MaxUint64), u)
+
+		var f float64
+		err = m.Unmarshal(json.Number("42.42"), &f)
+		assert.NoError(t, err)
+		assert.Equal(t, 42.42, f)
+
+		var small int8
+		err = m.Unmarshal(json.Number("300"), &small)
+		assert.Error(t, err)
+	})
+	t.Run("big number unmarshal", func(t *testing.T) {
+		var i *big.Int
+		err := m.Unmarshal(json.Number("123456789012345678901234567890"), &i)
+		assert.NoError(t, err)
+		assert.Equal(t, "123456789012345678901234567890", i.String())
+
+		var iv big.Int
+		err = m.Unmarshal(42, &iv)
+		assert.NoError(t, err)
+		assert.Equal(t, "42", iv.String())
+
+		var f big.Float
+		err = m.Unmarshal("1234567890.123456789012345678901", &f)
+		assert.NoError(t, err)
+		want, _ := new(big.Float).SetPrec(f.Prec()).SetString("1234567890.123456789012345678901")
+		assert.Equal(t, 0, want.Cmp(&f))
+
+		err = m.Unmarshal(map[string]any{}, &iv)
+		assert.Error(t, err)
+	})
 	t.Run("simple slice unmarshal", func(t *testing.T) {
 		var s []string
 		err := m.Unmarshal([]string{"test", "test2"}, &s)
@@ -67,6 +141,23 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("test"), b)
 	})
+	t.Run("bytes unmarshal", func(t *testing.T) {
+		tests := map[string]any{
+			"literal": `b"74657374"`,
+			"string":  "test",
+			"numbers": []any{json.Number("116"), json.Number("101"), json.Number("115"), json.Number("116")},
+		}
+		for name, src := range tests {
+			var b []byte
+			err := m.Unmarshal(src, &b)
+			assert.NoError(t, err, name)
+			assert.Equal(t, []byte("test"), b, name)
+		}
+
+		var b []byte
+		err := m.Unmarshal(`b"7g"`, &b)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+	})
 	t.Run("assign to any", func(t *testing.T) {
 		var a any
 		err := m.Unmarshal("test", &a)
@@ -79,6 +170,89 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, map[string]any{"test": "test", "num": 42}, m1)
 	})
+	t.Run("map to typed map", func(t *testing.T) {
+		type user struct {
+			Name string `db:"name"`
+		}
+		var users map[string]user
+		err := m.Unmarshal(map[string]any{
+			"a": map[string]any{"name": "john"},
+			"b": map[string]any{"name": "jane"},
+		}, &users)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]user{"a": {"john"}, "b": {"jane"}}, users)
+
+		var times map[string]time.Time
+		err = m.Unmarshal(map[string]any{"start": "2020-01-01T00:00:00Z"}, &times)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]time.Time{"start": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, times)
+
+		var ptrs map[string]*user
+		err = m.Unmarshal(map[string]any{"a": map[string]any{"name": "john"}, "b": nil}, &ptrs)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]*user{"a": {"john"}, "b": nil}, ptrs)
+	})
+	t.Run("map with non string keys", func(t *testing.T) {
+		var ints map[int]string
+		err := m.Unmarshal(map[string]any{"1": "a", "-2": "b"}, &ints)
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]string{1: "a", -2: "b"}, ints)
+
+		var uints map[uint8]bool
+		err = m.Unmarshal(map[string]any{"255": true}, &uints)
+		assert.NoError(t, err)
+		assert.Equal(t, map[uint8]bool{255: true}, uints)
+
+		err = m.Unmarshal(map[string]any{"256": true}, &uints)
+		assert.Error(t, err)
+
+		var bigs map[*big.Int]string
+		err = m.Unmarshal(map[string]any{"123456789012345678901234567890": "big"}, &bigs)
+		assert.NoError(t, err)
+		for k, v := range bigs {
+			assert.Equal(t, "123456789012345678901234567890", k.String())
+			assert.Equal(t, "big", v)
+		}
+
+		type key string
+		var named map[key]int
+		err = m.Unmarshal(map[string]any{"a": 1}, &named)
+		assert.NoError(t, err)
+		assert.Equal(t, map[key]int{"a": 1}, named)
+	})
+	t.Run("map value error has path", func(t *testing.T) {
+		var nested map[string]map[string]int
+		err := m.Unmarshal(map[string]any{"a": map[string]any{"b": "c"}}, &nested)
+		assert.EqualError(t, err, "a.b: cannot decode string into int")
+	})
+	t.Run("slice to array", func(t *testing.T) {
+		var a [3]int
+		err := m.Unmarshal([]any{1, 2}, &a)
+		assert.NoError(t, err)
+		assert.Equal(t, [3]int{1, 2, 0}, a)
+
+		var b [2]string
+		err = m.Unmarshal([]any{"a", "b", "c"}, &b)
+		assert.NoError(t, err)
+		assert.Equal(t, [2]string{"a", "b"}, b)
+
+		strict := Codec{Strict: true}
+		assert.Error(t, strict.Unmarshal([]any{"a", "b", "c"}, &b))
+	})
+	t.Run("named slice and array types", func(t *testing.T) {
+		type (
+			ids    []string
+			coords [2]float64
+			shape  struct {
+				IDs    ids    `db:"ids"`
+				Coords coords `db:"coords"`
+			}
+		)
+		var s shape
+		err := m.Unmarshal(map[string]any{"ids": []any{"a", "b"}, "coords": []any{1.5, 2.5}}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, shape{ids{"a", "b"}, coords{1.5, 2.5}}, s)
+	})
 	t.Run("map to struct", func(t *testing.T) {
 		type testStruct struct {
 			Test string
@@ -208,6 +382,43 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "test", s.Test)
 	})
+	t.Run("pointer to anonymous struct", func(t *testing.T) {
+		type Anonymous struct {
+			Test string `db:"test"`
+		}
+		type testStruct struct {
+			*Anonymous
+		}
+		var s testStruct
+		err := m.Unmarshal(map[string]any{"test": "test"}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", s.Test)
+	})
+	t.Run("tagged anonymous struct", func(t *testing.T) {
+		type Anonymous struct {
+			Test string `db:"test"`
+		}
+		type testStruct struct {
+			Anonymous `db:"anonymous"`
+		}
+		var s testStruct
+		err := m.Unmarshal(map[string]any{"test": "wrong", "anonymous": map[string]any{"test": "test"}}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", s.Test)
+	})
+	t.Run("shallow field hides embedded field", func(t *testing.T) {
+		type Anonymous struct {
+			Test string `db:"test"`
+		}
+		type testStruct struct {
+			Anonymous
+			Test string `db:"test"`
+		}
+		var s testStruct
+		err := m.Unmarshal(map[string]any{"test": "test"}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, testStruct{Test: "test"}, s)
+	})
 	t.Run("test with omitempty db field", func(t *testing.T) {
 		type testStruct struct {
 			Test string `db:",omitempty"`
@@ -236,3 +447,202 @@ func TestMarshaler_Unmarshal(t *testing.T) {
 		assert.Equal(t, testStruct{"test"}, s)
 	})
 }
+
+func TestMarshaler_StrictUnmarshal(t *testing.T) {
+	m := Codec{FallbackTag: "json", Strict: true}
+
+	type (
+		item struct {
+			Price float64 `db:"price"`
+		}
+		order struct {
+			ID    string `db:"id,required"`
+			Items []item `db:"items"`
+		}
+		testStruct struct {
+			Orders []order `db:"orders"`
+		}
+	)
+	orders := func(price any) map[string]any {
+		items := []any{map[string]any{"price": 1.5}}
+		if price != nil {
+			items = []any{map[string]any{"price": price}}
+		}
+
+		o := make([]any, 4)
+		for i := range o {
+			o[i] = map[string]any{"id": "order:" + strconv.Itoa(i), "items": []any{map[string]any{"price": 1.5}}}
+		}
+		o[3] = map[string]any{"id": "order:3", "items": items}
+		return map[string]any{"orders": o}
+	}
+
+	t.Run("valid source", func(t *testing.T) {
+		var s testStruct
+		err := m.Unmarshal(orders(nil), &s)
+		assert.NoError(t, err)
+		assert.Len(t, s.Orders, 4)
+	})
+	t.Run("type mismatch has path", func(t *testing.T) {
+		var s testStruct
+		err := m.Unmarshal(orders("1.5"), &s)
+
+		var ue *errs.UnmarshalError
+		assert.ErrorAs(t, err, &ue)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.Equal(t, "orders[3].items[0].price", ue.Path)
+		assert.EqualError(t, err, "orders[3].items[0].price: cannot decode string into float64")
+	})
+	t.Run("unknown field", func(t *testing.T) {
+		var s testStruct
+		src := orders(nil)
+		src["orders"].([]any)[1].(map[string]any)["total"] = 3
+		err := m.Unmarshal(src, &s)
+		assert.EqualError(t, err, "orders[1].total: unknown field")
+	})
+	t.Run("missing required field", func(t *testing.T) {
+		var s testStruct
+		src := orders(nil)
+		delete(src["orders"].([]any)[2].(map[string]any), "id")
+		err := m.Unmarshal(src, &s)
+		assert.EqualError(t, err, "orders[2].id: missing required field")
+	})
+	t.Run("lossy number conversion", func(t *testing.T) {
+		var i int
+		assert.EqualError(t, m.Unmarshal(1.5, &i), "cannot decode 1.5 into int without loss")
+		assert.NoError(t, m.Unmarshal(2.0, &i))
+		assert.Equal(t, 2, i)
+
+		var u uint8
+		assert.Error(t, m.Unmarshal(-1, &u))
+		assert.Error(t, m.Unmarshal(json.Number("256"), &u))
+		assert.NoError(t, m.Unmarshal(json.Number("255"), &u))
+	})
+	t.Run("no conversion between kinds", func(t *testing.T) {
+		var s string
+		assert.EqualError(t, m.Unmarshal(65, &s), "cannot decode int into string")
+
+		var d time.Duration
+		assert.Error(t, m.Unmarshal(float64(time.Second), &d))
+	})
+	t.Run("lenient mode ignores unknown and missing fields", func(t *testing.T) {
+		lenient := Codec{FallbackTag: "json"}
+		src := orders(1)
+		src["unknown"] = true
+		delete(src["orders"].([]any)[0].(map[string]any), "id")
+
+		var s testStruct
+		assert.NoError(t, lenient.Unmarshal(src, &s))
+		assert.Equal(t, 1.0, s.Orders[3].Items[0].Price)
+
+		var i int
+		assert.NoError(t, lenient.Unmarshal(1.5, &i))
+		assert.Equal(t, 1, i)
+	})
+	t.Run("lenient mode reports mismatches with path", func(t *testing.T) {
+		lenient := Codec{FallbackTag: "json"}
+		var s testStruct
+		err := lenient.Unmarshal(orders("1.5"), &s)
+		assert.EqualError(t, err, "orders[3].items[0].price: cannot decode string into float64")
+	})
+}
+
+// TestMarshaler_ReuseDestination documents how Unmarshal treats a destination
+// which already holds values, e.g. a pooled struct:
+//   - by default, zero values such as false, 0 or "" overwrite the current value
+//     and NULL as well as fields which are missing from the source reset it to
+//     its zero value.
+//   - with Merge, NULL, zero values and missing fields keep the current value.
+//   - in both modes, slices and maps are replaced rather than merged element by
+//     element.
+func TestMarshaler_ReuseDestination(t *testing.T) {
+	type inner struct {
+		N int `db:"n"`
+	}
+	type testStruct struct {
+		Flag    bool           `db:"flag"`
+		Count   int            `db:"count"`
+		Name    string         `db:"name"`
+		Ptr     *inner         `db:"ptr"`
+		Tags    []string       `db:"tags"`
+		Labels  map[string]int `db:"labels"`
+		Missing string         `db:"missing"`
+	}
+	stale := func() testStruct {
+		return testStruct{
+			Flag:    true,
+			Count:   5,
+			Name:    "stale",
+			Ptr:     &inner{N: 1},
+			Tags:    []string{"a", "b"},
+			Labels:  map[string]int{"a": 1},
+			Missing: "stale",
+		}
+	}
+	src := map[string]any{
+		"flag":   false,
+		"count":  json.Number("0"),
+		"name":   "",
+		"ptr":    nil,
+		"tags":   []any{"c"},
+		"labels": map[string]any{"b": json.Number("2")},
+	}
+
+	t.Run("overwrite by default", func(t *testing.T) {
+		m := Codec{}
+		s := stale()
+		err := m.Unmarshal(src, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, testStruct{
+			Tags:   []string{"c"},
+			Labels: map[string]int{"b": 2},
+		}, s)
+	})
+	t.Run("null resets", func(t *testing.T) {
+		m := Codec{}
+		n := 5
+		assert.NoError(t, m.Unmarshal(nil, &n))
+		assert.Equal(t, 0, n)
+
+		s := stale()
+		assert.NoError(t, m.Unmarshal(map[string]any{"tags": nil, "labels": nil, "name": nil}, &s))
+		assert.Nil(t, s.Tags)
+		assert.Nil(t, s.Labels)
+		assert.Empty(t, s.Name)
+	})
+	t.Run("missing fields reset", func(t *testing.T) {
+		type Embedded struct {
+			E string `db:"e"`
+		}
+		type withEmbedded struct {
+			*Embedded
+			Name string `db:"name"`
+		}
+		m := Codec{}
+
+		s := withEmbedded{Embedded: &Embedded{E: "stale"}, Name: "stale"}
+		assert.NoError(t, m.Unmarshal(map[string]any{"name": "new"}, &s))
+		assert.Equal(t, withEmbedded{Embedded: &Embedded{}, Name: "new"}, s)
+
+		// nil embedded pointers are not allocated for missing fields
+		s = withEmbedded{}
+		assert.NoError(t, m.Unmarshal(map[string]any{"name": "new"}, &s))
+		assert.Equal(t, withEmbedded{Name: "new"}, s)
+
+		m.Merge = true
+		s = withEmbedded{Embedded: &Embedded{E: "stale"}, Name: "stale"}
+		assert.NoError(t, m.Unmarshal(map[string]any{"name": "new"}, &s))
+		assert.Equal(t, withEmbedded{Embedded: &Embedded{E: "stale"}, Name: "new"}, s)
+	})
+	t.Run("merge keeps current values", func(t *testing.T) {
+		m := Codec{Merge: true}
+		s := stale()
+		err := m.Unmarshal(src, &s)
+		assert.NoError(t, err)
+
+		want := stale()
+		want.Tags = []string{"c"}
+		want.Labels = map[string]int{"b": 2}
+		assert.Equal(t, want, s)
+	})
+}
@@ -0,0 +1,110 @@
+// Package migrate applies versioned SurrealQL migrations to a database.
+//
+// Migrations are .surql files named <version>_<name>.up.surql, which are
+// applied in the order of their versions, and optionally
+// <version>_<name>.down.surql, which revert them. A file without the .up
+// suffix is an up migration as well. They are usually embedded:
+//
+//	//go:embed migrations/*.surql
+//	var migrations embed.FS
+//
+//	sub, _ := fs.Sub(migrations, "migrations")
+//	m, err := migrate.New(db, sub)
+//	if err != nil {
+//		// handle error
+//	}
+//	err = m.Up(ctx)
+//
+// The applied migrations and their checksums are recorded in a table of the
+// database, see WithTable.
+package migrate
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/NoBypass/surgo/v2/errs"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Migration is a versioned change of the database.
+type Migration struct {
+	Version uint64
+	Name    string
+	// Up and Down are the SurrealQL statements which apply and revert the
+	// migration. Down is empty if the migration can't be reverted.
+	Up   string
+	Down string
+	// Checksum is the hex encoded SHA-256 hash of Up.
+	Checksum string
+}
+
+// Load reads the migrations from the .surql files in the root directory of
+// fsys, ordered by their versions. Other files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errs.ErrMigration.With(err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, e := range entries {
+		base, ok := strings.CutSuffix(e.Name(), ".surql")
+		if e.IsDir() || !ok {
+			continue
+		}
+
+		base, down := strings.CutSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".up")
+		v, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errs.ErrMigration.Withf("invalid file name %q, expected <version>_<name>.surql", e.Name())
+		}
+
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, errs.ErrMigration.With(err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, errs.ErrMigration.Withf("version %d is used by %q and %q", version, m.Name, name)
+		}
+
+		if down {
+			m.Down = string(b)
+		} else if m.Checksum != "" {
+			return nil, errs.ErrMigration.Withf("migration %d has several up files", version)
+		} else {
+			sum := sha256.Sum256(b)
+			m.Up, m.Checksum = string(b), hex.EncodeToString(sum[:])
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, errs.ErrMigration.Withf("migration %d has a down but no up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// String returns the version and the name of the migration.
+func (m Migration) String() string {
+	if m.Name == "" {
+		return strconv.FormatUint(m.Version, 10)
+	}
+	return strconv.FormatUint(m.Version, 10) + "_" + m.Name
+}
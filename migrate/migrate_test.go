@@ -0,0 +1,287 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/internal/dbtest"
+	"github.com/NoBypass/surgo/v2/internal/surgotest"
+	"github.com/stretchr/testify/assert"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+var files = fstest.MapFS{
+	"0001_users.up.surql":     {Data: []byte("DEFINE TABLE users SCHEMAFULL;\nDEFINE FIELD name ON users TYPE string;")},
+	"0001_users.down.surql":   {Data: []byte("REMOVE TABLE users;")},
+	"0002_posts.surql":        {Data: []byte("DEFINE TABLE posts SCHEMALESS;")},
+	"0010_indexes.up.surql":   {Data: []byte("DEFINE INDEX users_name ON users FIELDS name;")},
+	"0010_indexes.down.surql": {Data: []byte("REMOVE INDEX users_name ON users;")},
+	"README.md":               {Data: []byte("# Migrations")},
+}
+
+// database fakes the records of the migrations table and the lock.
+type database struct {
+	mu      sync.Mutex
+	applied map[int64]string
+	locked  bool
+	// fail makes statements containing it fail.
+	fail string
+	// executed are the statements of the migrations which were executed.
+	executed []string
+}
+
+func newDatabase() *database {
+	return &database{applied: make(map[int64]string)}
+}
+
+func (d *database) handle(q surgotest.Query) []any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	number := func(name string) int64 {
+		n, _ := q.Vars[name].(json.Number).Int64()
+		return n
+	}
+
+	switch {
+	case strings.HasPrefix(q.Query, "SELECT"):
+		records := []any{}
+		for v, checksum := range d.applied {
+			records = append(records, map[string]any{"id": fmt.Sprintf("surgo_migrations:%d", v), "version": v, "checksum": checksum, "name": "applied", "applied_at": "2024-01-01T00:00:00Z"})
+		}
+		return []any{records}
+	case strings.HasPrefix(q.Query, "CREATE type::thing($lock"):
+		if d.locked {
+			return []any{errors.New("Database record `surgo_migrations_lock:lock` already exists")}
+		}
+		d.locked = true
+		return []any{[]any{map[string]any{"id": "surgo_migrations_lock:lock"}}}
+	case strings.HasPrefix(q.Query, "DELETE type::thing($lock"):
+		d.locked = false
+		return []any{[]any{}}
+	}
+
+	statements := strings.Split(q.Query, ";\n")
+	statements = statements[1 : len(statements)-1]
+	if d.fail != "" && strings.Contains(q.Query, d.fail) {
+		results := make([]any, len(statements))
+		for i, s := range statements {
+			if strings.Contains(s, d.fail) {
+				results[i] = errors.New("There was a problem with the database: " + d.fail)
+			} else {
+				results[i] = errors.New("The query was not executed due to a failed transaction")
+			}
+		}
+		return results
+	}
+
+	results := make([]any, 0, len(statements))
+	for _, s := range statements {
+		switch {
+		case strings.HasPrefix(s, "CREATE type::thing($surgo_table"):
+			d.applied[number("surgo_version")] = q.Vars["surgo_checksum"].(string)
+		case strings.HasPrefix(s, "DELETE type::thing($surgo_table"):
+			delete(d.applied, number("surgo_version"))
+		case strings.HasPrefix(s, "DELETE type::table($table)"):
+			for v := range d.applied {
+				if v > number("version") {
+					delete(d.applied, v)
+				}
+			}
+		case strings.HasPrefix(s, "FOR $m"):
+			for _, m := range q.Vars["migrations"].([]any) {
+				m := m.(map[string]any)
+				v, _ := m["version"].(json.Number).Int64()
+				d.applied[v] = m["checksum"].(string)
+			}
+		case strings.HasPrefix(s, "DELETE type::thing($lock"):
+			d.locked = false
+		default:
+			d.executed = append(d.executed, s)
+		}
+		results = append(results, nil)
+	}
+	return results
+}
+
+func newMigrator(t *testing.T) (*Migrator, *database) {
+	d := newDatabase()
+	db, _ := dbtest.New(t, d.handle, surgo.WithStrictUnmarshal(), surgo.WithVarCheck())
+	m, err := New(db, files)
+	assert.NoError(t, err)
+	return m, d
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		migrations, err := Load(files)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 3)
+
+		assert.Equal(t, uint64(1), migrations[0].Version)
+		assert.Equal(t, "users", migrations[0].Name)
+		assert.Equal(t, "REMOVE TABLE users;", migrations[0].Down)
+		assert.Equal(t, "1_users", migrations[0].String())
+		assert.Len(t, migrations[0].Checksum, 64)
+
+		assert.Equal(t, "DEFINE TABLE posts SCHEMALESS;", migrations[1].Up)
+		assert.Empty(t, migrations[1].Down)
+		assert.Equal(t, uint64(10), migrations[2].Version)
+	})
+	t.Run("errors", func(t *testing.T) {
+		for name, fsys := range map[string]fstest.MapFS{
+			"invalid version": {"v1_users.surql": {}},
+			"duplicate version": {
+				"1_users.surql": {},
+				"1_posts.surql": {},
+			},
+			"several up files": {
+				"1_users.surql":    {},
+				"1_users.up.surql": {},
+			},
+			"down without up": {"1_users.down.surql": {}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := Load(fsys)
+				assert.ErrorIs(t, err, errs.ErrMigration)
+			})
+		}
+	})
+}
+
+func TestMigrator_Up(t *testing.T) {
+	t.Run("pending", func(t *testing.T) {
+		m, d := newMigrator(t)
+		d.applied[1] = m.migrations[0].Checksum
+
+		assert.NoError(t, m.Up(context.Background()))
+		assert.Equal(t, []string{
+			"DEFINE TABLE posts SCHEMALESS",
+			"DEFINE INDEX users_name ON users FIELDS name",
+		}, d.executed)
+		assert.Len(t, d.applied, 3)
+		assert.False(t, d.locked)
+
+		assert.NoError(t, m.Up(context.Background()))
+		assert.Len(t, d.executed, 2)
+	})
+	t.Run("failed migration", func(t *testing.T) {
+		m, d := newMigrator(t)
+		d.fail = "posts"
+
+		err := m.Up(context.Background())
+		assert.ErrorIs(t, err, errs.ErrMigration)
+		assert.ErrorIs(t, err, errs.ErrDatabase)
+		assert.ErrorContains(t, err, "up 2_posts")
+		assert.Equal(t, []int64{1}, keys(d.applied))
+		assert.False(t, d.locked)
+	})
+	t.Run("modified", func(t *testing.T) {
+		m, d := newMigrator(t)
+		d.applied[1] = "outdated"
+
+		err := m.Up(context.Background())
+		assert.ErrorIs(t, err, errs.ErrMigration)
+		assert.ErrorContains(t, err, "1_users was modified")
+		assert.Empty(t, d.executed)
+	})
+	t.Run("locked", func(t *testing.T) {
+		m, d := newMigrator(t)
+		d.locked = true
+
+		err := m.Up(context.Background())
+		assert.ErrorIs(t, err, errs.ErrLocked)
+		assert.Empty(t, d.executed)
+		assert.True(t, d.locked)
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	t.Run("last", func(t *testing.T) {
+		m, d := newMigrator(t)
+		assert.NoError(t, m.Up(context.Background()))
+		d.executed = nil
+
+		assert.NoError(t, m.Down(context.Background()))
+		assert.Equal(t, []string{"REMOVE INDEX users_name ON users"}, d.executed)
+		assert.Equal(t, []int64{1, 2}, keys(d.applied))
+	})
+	t.Run("no down file", func(t *testing.T) {
+		m, d := newMigrator(t)
+		d.applied[1], d.applied[2] = m.migrations[0].Checksum, m.migrations[1].Checksum
+
+		err := m.Down(context.Background())
+		assert.ErrorIs(t, err, errs.ErrMigration)
+		assert.ErrorContains(t, err, "2_posts has no down file")
+	})
+	t.Run("nothing applied", func(t *testing.T) {
+		m, _ := newMigrator(t)
+		assert.ErrorIs(t, m.Down(context.Background()), errs.ErrMigration)
+	})
+}
+
+func TestMigrator_Status(t *testing.T) {
+	m, d := newMigrator(t)
+	d.applied[1] = "outdated"
+	d.applied[2] = m.migrations[1].Checksum
+	d.applied[5] = "removed"
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 4)
+
+	assert.True(t, statuses[0].Applied)
+	assert.True(t, statuses[0].Modified)
+	assert.Equal(t, 2024, statuses[0].AppliedAt.Year())
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[1].Modified)
+	assert.Equal(t, uint64(5), statuses[2].Version)
+	assert.True(t, statuses[2].Missing)
+	assert.False(t, statuses[3].Applied)
+}
+
+func TestMigrator_Force(t *testing.T) {
+	m, d := newMigrator(t)
+	d.applied[1] = "outdated"
+	d.applied[10] = m.migrations[2].Checksum
+	d.locked = true
+
+	assert.NoError(t, m.Force(context.Background(), 2))
+	assert.Equal(t, []int64{1, 2}, keys(d.applied))
+	assert.Equal(t, m.migrations[0].Checksum, d.applied[1])
+	assert.False(t, d.locked)
+	assert.Empty(t, d.executed)
+
+	assert.ErrorIs(t, m.Force(context.Background(), 3), errs.ErrMigration)
+
+	assert.NoError(t, m.Force(context.Background(), 0))
+	assert.Empty(t, d.applied)
+}
+
+func TestNew_WithTable(t *testing.T) {
+	db, srv := dbtest.New(t, func(q surgotest.Query) []any {
+		return []any{[]any{}}
+	})
+	m, err := New(db, fstest.MapFS{}, WithTable("migrations"))
+	assert.NoError(t, err)
+
+	_, err = m.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, surgo.Vars{"table": "migrations"}, surgo.Vars(srv.Queries()[0].Vars))
+}
+
+func keys(m map[int64]string) []int64 {
+	var ks []int64
+	for k := range m {
+		ks = append(ks, k)
+	}
+	slices.Sort(ks)
+	return ks
+}
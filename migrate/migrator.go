@@ -0,0 +1,252 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/rand"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+)
+
+// lockID is the id of the lock record in the lock table.
+const lockID = "lock"
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *surgo.DB
+	migrations []Migration
+	table      string
+	// owner identifies the Migrator in the lock record.
+	owner string
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithTable sets the table in which the applied migrations are recorded. It
+// defaults to surgo_migrations. The lock record is stored in the table of the
+// same name with a _lock suffix.
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// Status is the state of a migration in the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified reports whether the file of an applied migration was changed
+	// since it was applied.
+	Modified bool
+	// Missing reports whether an applied migration has no file. Only the
+	// version, name and checksum of its Migration are known.
+	Missing bool
+}
+
+// record is a migration recorded in the database.
+type record struct {
+	ID        string    `db:"id,omitempty"`
+	Version   uint64    `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// New loads the migrations from fsys, see Load, and returns a Migrator which
+// applies them to the database.
+func New(db *surgo.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	m := &Migrator{
+		db:         db,
+		migrations: migrations,
+		table:      "surgo_migrations",
+		owner:      fmt.Sprintf("%s:%d:%s", host, os.Getpid(), rand.String(8)),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Status returns the state of all migrations, ordered by their versions.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	return m.status(m.db.WithContext(ctx))
+}
+
+// Up applies all pending migrations in the order of their versions. Each one
+// is applied in a transaction together with its record, so a failed migration
+// leaves no trace. Migrations must therefore not contain transaction
+// statements of their own. If an applied migration was modified, nothing is
+// applied and errs.ErrMigration is returned, see Force.
+//
+// While migrating, the Migrator holds a lock record. If another Migrator holds
+// it, errs.ErrLocked is returned.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(db *surgo.DB) error {
+		statuses, err := m.status(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Modified {
+				return errs.ErrMigration.Withf("migration %s was modified after it was applied", s.Migration)
+			}
+		}
+
+		for _, s := range statuses {
+			if s.Applied {
+				continue
+			}
+			err := db.Tx(ctx, func(tx *surgo.Tx) error {
+				tx.Query(s.Up, nil)
+				tx.Query("CREATE type::thing($surgo_table, $surgo_version) SET version = $surgo_version, name = $surgo_name, checksum = $surgo_checksum, applied_at = time::now()", surgo.Vars{
+					"surgo_table":    m.table,
+					"surgo_version":  s.Version,
+					"surgo_name":     s.Name,
+					"surgo_checksum": s.Checksum,
+				})
+				return nil
+			})
+			if err != nil {
+				return errs.ErrMigration.Withf("up %s: %w", s.Migration, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last applied migration in a transaction. It returns
+// errs.ErrMigration if no migration is applied or the last one has no down
+// file. Like Up, it holds the lock while migrating.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(db *surgo.DB) error {
+		statuses, err := m.status(db)
+		if err != nil {
+			return err
+		}
+
+		i := len(statuses) - 1
+		for i >= 0 && !statuses[i].Applied {
+			i--
+		}
+		if i < 0 {
+			return errs.ErrMigration.Withf("no migration has been applied")
+		}
+		last := statuses[i]
+		if last.Missing {
+			return errs.ErrMigration.Withf("migration %s has no file", last.Migration)
+		} else if last.Down == "" {
+			return errs.ErrMigration.Withf("migration %s has no down file", last.Migration)
+		}
+
+		err = db.Tx(ctx, func(tx *surgo.Tx) error {
+			tx.Query(last.Down, nil)
+			tx.Query("DELETE type::thing($surgo_table, $surgo_version)", surgo.Vars{
+				"surgo_table":   m.table,
+				"surgo_version": last.Version,
+			})
+			return nil
+		})
+		if err != nil {
+			return errs.ErrMigration.Withf("down %s: %w", last.Migration, err)
+		}
+		return nil
+	})
+}
+
+// Force records the migrations up to and including the version as applied
+// and all later ones as pending, without executing them. The checksums of
+// modified migrations are updated. A version of 0 marks all migrations as
+// pending. Force is meant to recover from failures, so it also removes the
+// lock of a Migrator which did not finish.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	var applied []record
+	for _, mig := range m.migrations {
+		if mig.Version <= version {
+			applied = append(applied, record{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum})
+		}
+	}
+	if version != 0 && (len(applied) == 0 || applied[len(applied)-1].Version != version) {
+		return errs.ErrMigration.Withf("unknown version %d", version)
+	}
+
+	db := m.db.WithContext(ctx)
+	return db.Tx(ctx, func(tx *surgo.Tx) error {
+		tx.Query("DELETE type::table($table) WHERE version > $version", surgo.Vars{"table": m.table, "version": version})
+		if len(applied) > 0 {
+			tx.Query("FOR $m IN $migrations { UPSERT type::thing($table, $m.version) SET version = $m.version, name = $m.name, checksum = $m.checksum, applied_at = applied_at ?? time::now() }", surgo.Vars{
+				"table":      m.table,
+				"migrations": applied,
+			})
+		}
+		tx.Query("DELETE type::thing($lock, $id)", surgo.Vars{"lock": m.table + "_lock", "id": lockID})
+		return nil
+	})
+}
+
+// status returns the state of the migrations and of applied migrations
+// without a file.
+func (m *Migrator) status(db *surgo.DB) ([]Status, error) {
+	records, err := surgo.QueryAs[record](db, "SELECT * FROM type::table($table) ORDER BY version", surgo.Vars{"table": m.table})
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint64]record, len(records))
+	for _, r := range records {
+		byVersion[r.Version] = r
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if r, ok := byVersion[mig.Version]; ok {
+			s.Applied, s.AppliedAt, s.Modified = true, r.AppliedAt, r.Checksum != mig.Checksum
+			delete(byVersion, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, r := range byVersion {
+		statuses = append(statuses, Status{
+			Migration: Migration{Version: r.Version, Name: r.Name, Checksum: r.Checksum},
+			Applied:   true,
+			AppliedAt: r.AppliedAt,
+			Missing:   true,
+		})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return statuses, nil
+}
+
+// locked runs fn while holding the lock record.
+func (m *Migrator) locked(ctx context.Context, fn func(db *surgo.DB) error) (err error) {
+	db := m.db.WithContext(ctx)
+	vars := surgo.Vars{"lock": m.table + "_lock", "id": lockID, "owner": m.owner}
+
+	_, err = db.Query("CREATE type::thing($lock, $id) SET owner = $owner, locked_at = time::now()", vars).Last()
+	if errors.Is(err, errs.ErrAlreadyExists) {
+		return errs.ErrLocked.Withf("the record %s:%s exists, either another Migrator is running or one did not finish, in which case Force removes the lock", vars["lock"], lockID)
+	} else if err != nil {
+		return err
+	}
+
+	defer func() {
+		_, unlockErr := db.Query("DELETE type::thing($lock, $id) WHERE owner = $owner", vars).Last()
+		err = errors.Join(err, unlockErr)
+	}()
+	return fn(db)
+}
@@ -0,0 +1,236 @@
+package surgo
+
+import (
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/qb"
+	"github.com/NoBypass/surgo/v2/sql"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// trailingClauses are the clauses of a SELECT statement which follow LIMIT and
+// START.
+var trailingClauses = []string{"FETCH", "TIMEOUT", "PARALLEL", "TEMPFILES", "EXPLAIN"}
+
+// clauseKeywordPredecessors are the words after which a clause keyword is a
+// field name or alias instead, e.g. ORDER BY timeout.
+var clauseKeywordPredecessors = []string{"SELECT", "VALUE", "FROM", "ONLY", "WHERE", "BY", "AND", "OR", "NOT", "IS", "IN", "AS", "FETCH", "SPLIT", "ON"}
+
+// Paginate executes the query page by page with LIMIT and START and returns
+// the records one by one. The query must be a single SELECT statement without
+// LIMIT and START of its own, otherwise errs.ErrUnsupported is returned. It
+// should be ordered, as SurrealDB does not guarantee the order of records
+// otherwise. LIMIT and START are inserted before the FETCH, TIMEOUT,
+// PARALLEL, TEMPFILES and EXPLAIN clauses of the query. A page is only fetched once the records of
+// the previous one have been consumed, so iteration can be stopped at any
+// time. The context of the DB, see WithContext, is checked before each page.
+//
+// As every page has to skip the records of the previous ones, PaginateByID
+// is faster for large tables.
+func Paginate[T any](db *DB, query string, vars any, pageSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		statements := splitStatements(query)
+		if len(statements) != 1 {
+			yield(zero, errs.ErrUnsupported.Withf("Paginate requires a single statement, got %d", len(statements)))
+			return
+		} else if pageSize <= 0 {
+			yield(zero, errs.ErrOutOfBounds.Withf("page size %d", pageSize))
+			return
+		}
+
+		query, err := paginated(statements[0])
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		marshaled, err := db.codec().MarshalVars(vars)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for start := 0; ; start += pageSize {
+			if err := safeContext(db.ctx).Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			page := maps.Clone(marshaled)
+			if page == nil {
+				page = make(map[string]any, 2)
+			}
+			page["surgo_limit"], page["surgo_start"] = pageSize, start
+
+			res, err := QueryAs[T](db, query, page)
+			if errors.Is(err, errs.ErrNoResult) {
+				return
+			} else if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			for _, v := range res {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if len(res) < pageSize {
+				return
+			}
+		}
+	}
+}
+
+// paginated adds LIMIT and START to the SELECT statement, before the clauses
+// which have to follow them.
+func paginated(statement string) (string, error) {
+	rs := []rune(statement)
+	tokens := topLevelTokens(rs)
+	insert := len(rs)
+	for i, t := range tokens {
+		if !isClauseKeyword(tokens, i) {
+			continue
+		}
+		switch kw := strings.ToUpper(t.text); {
+		case kw == "LIMIT" || kw == "START":
+			return "", errs.ErrUnsupported.Withf("Paginate adds LIMIT and START itself, but the query has a %s clause", kw)
+		case slices.Contains(trailingClauses, kw) && insert == len(rs):
+			insert = t.start
+		}
+	}
+
+	query := strings.TrimSpace(string(rs[:insert])) + " LIMIT $surgo_limit START $surgo_start"
+	if rest := strings.TrimSpace(string(rs[insert:])); rest != "" {
+		query += " " + rest
+	}
+	return query, nil
+}
+
+// token is a word, param or other character of a statement outside of
+// brackets, strings and comments. Brackets, strings and record ids in ⟨⟩ are
+// a single token of their first character.
+type token struct {
+	start int
+	text  string
+	word  bool
+}
+
+func topLevelTokens(rs []rune) []token {
+	var tokens []token
+	for i := 0; i < len(rs); i++ {
+		start := i
+		switch r := rs[i]; {
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' {
+					i++
+				}
+			}
+		case r == '⟨':
+			for i++; i < len(rs) && rs[i] != '⟩'; i++ {
+			}
+		case r == '#' || r == '-' && next(rs, i) == '-' || r == '/' && next(rs, i) == '/':
+			for i++; i < len(rs) && rs[i] != '\n'; i++ {
+			}
+			continue
+		case r == '/' && next(rs, i) == '*':
+			for i += 2; i < len(rs) && !(rs[i] == '*' && next(rs, i) == '/'); i++ {
+			}
+			i++
+			continue
+		case r == '{' || r == '(' || r == '[':
+			for depth := 1; depth > 0 && i+1 < len(rs); {
+				i++
+				switch rs[i] {
+				case '{', '(', '[':
+					depth++
+				case '}', ')', ']':
+					depth--
+				}
+			}
+		case r == '$' || isIdentRune(r):
+			for i++; i < len(rs) && isIdentRune(rs[i]); i++ {
+			}
+			tokens = append(tokens, token{start: start, text: string(rs[start:i]), word: r != '$'})
+			i--
+			continue
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			continue
+		}
+		tokens = append(tokens, token{start: start, text: string(rs[start])})
+	}
+	return tokens
+}
+
+// isClauseKeyword reports whether the word at tokens[i] can start a clause.
+// Words which are part of an expression, e.g. the field of timeout > 5 or
+// user.limit, are not.
+func isClauseKeyword(tokens []token, i int) bool {
+	if !tokens[i].word || i == 0 {
+		return false
+	}
+	prev := tokens[i-1]
+	if prev.word && slices.Contains(clauseKeywordPredecessors, strings.ToUpper(prev.text)) {
+		return false
+	} else if !prev.word && strings.ContainsAny(prev.text, ".:,=<>!?+-*/~@|&") {
+		return false
+	}
+	return i+1 == len(tokens) || !strings.ContainsAny(tokens[i+1].text, ".:,=<>!?+-*/~@|&[")
+}
+
+// PaginateByID returns the records of the table which match the filter, which
+// may be nil, ordered by their id. It works like Paginate, but each page
+// starts after the id of the last record of the previous page, so no records
+// have to be skipped and records which are created or deleted while iterating
+// do not shift the pages.
+func PaginateByID[T any](db *DB, table string, filter qb.Cond, pageSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if pageSize <= 0 {
+			yield(zero, errs.ErrOutOfBounds.Withf("page size %d", pageSize))
+			return
+		}
+
+		var cursor any
+		for {
+			if err := safeContext(db.ctx).Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			q := qb.Select().From(sql.Ident(table)).Where(filter).OrderBy("id").Limit(pageSize)
+			if cursor != nil {
+				q.Where(qb.Expr("id > <record> ?", cursor))
+			}
+
+			res, err := db.Query(q.Build()).Last()
+			if errors.Is(err, errs.ErrNoResult) {
+				return
+			} else if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			rs := records(res)
+			for _, rec := range rs {
+				var v T
+				if err := db.codec().Unmarshal(rec, &v); err != nil {
+					yield(zero, err)
+					return
+				} else if !yield(v, nil) {
+					return
+				}
+				if m, ok := rec.(map[string]any); ok {
+					cursor = m["id"]
+				}
+			}
+			if len(rs) < pageSize || cursor == nil {
+				return
+			}
+		}
+	}
+}
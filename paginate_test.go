@@ -0,0 +1,165 @@
+package surgo
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/qb"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+// offsetPages answers queries with n records, named by their index, using
+// the LIMIT and START vars of Paginate.
+func offsetPages(n int) handlerFunc {
+	return func(req rpc.Request) (any, *rpc.Error) {
+		vars, _ := req.Params[1].(map[string]any)
+		limit, _ := vars["surgo_limit"].(json.Number).Int64()
+		start, _ := vars["surgo_start"].(json.Number).Int64()
+
+		var page []any
+		for i := int(start); i < min(int(start+limit), n); i++ {
+			page = append(page, map[string]any{"id": "users:" + strconv.Itoa(i), "name": strconv.Itoa(i)})
+		}
+		return statements(page)(req)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	t.Run("all pages", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		db := newTestDB(t, recordQueries(&queries, &vars, offsetPages(5)))
+
+		var names []string
+		for user, err := range Paginate[testUser](db, "SELECT * FROM users WHERE age > $age ORDER BY name;", Vars{"age": 18}, 2) {
+			assert.NoError(t, err)
+			names = append(names, user.Name)
+		}
+		assert.Equal(t, []string{"0", "1", "2", "3", "4"}, names)
+		assert.Len(t, queries, 3)
+		assert.Equal(t, "SELECT * FROM users WHERE age > $age ORDER BY name LIMIT $surgo_limit START $surgo_start", queries[0])
+		assert.Equal(t, json.Number("18"), vars[2]["age"])
+		assert.Equal(t, json.Number("4"), vars[2]["surgo_start"])
+	})
+	t.Run("exact pages", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		db := newTestDB(t, recordQueries(&queries, &vars, offsetPages(4)))
+
+		var n int
+		for _, err := range Paginate[testUser](db, "SELECT * FROM users", nil, 2) {
+			assert.NoError(t, err)
+			n++
+		}
+		assert.Equal(t, 4, n)
+		assert.Len(t, queries, 3)
+	})
+	t.Run("break", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		db := newTestDB(t, recordQueries(&queries, &vars, offsetPages(5)))
+
+		for range Paginate[testUser](db, "SELECT * FROM users", nil, 2) {
+			break
+		}
+		assert.Len(t, queries, 1)
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		db := newTestDB(t, offsetPages(5)).WithContext(ctx)
+
+		var n int
+		var err error
+		for _, err = range Paginate[testUser](db, "SELECT * FROM users", nil, 2) {
+			if err != nil {
+				break
+			} else if n++; n == 2 {
+				cancel()
+			}
+		}
+		assert.Equal(t, 2, n)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("multiple statements", func(t *testing.T) {
+		db := newTestDB(t, offsetPages(5))
+		for _, err := range Paginate[testUser](db, "LET $a = 1; SELECT * FROM users", nil, 2) {
+			assert.ErrorIs(t, err, errs.ErrUnsupported)
+		}
+	})
+	t.Run("invalid page size", func(t *testing.T) {
+		db := newTestDB(t, offsetPages(5))
+		for _, err := range Paginate[testUser](db, "SELECT * FROM users", nil, 0) {
+			assert.ErrorIs(t, err, errs.ErrOutOfBounds)
+		}
+	})
+	t.Run("fetch", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		db := newTestDB(t, recordQueries(&queries, &vars, offsetPages(1)))
+
+		for _, err := range Paginate[testUser](db, "SELECT * FROM users ORDER BY name FETCH friends, posts.author TIMEOUT 5s", nil, 2) {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"SELECT * FROM users ORDER BY name LIMIT $surgo_limit START $surgo_start FETCH friends, posts.author TIMEOUT 5s"}, queries)
+	})
+	t.Run("limit", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		db := newTestDB(t, recordQueries(&queries, &vars, offsetPages(5)))
+
+		for _, err := range Paginate[testUser](db, "SELECT * FROM users ORDER BY name LIMIT 10", nil, 2) {
+			assert.ErrorIs(t, err, errs.ErrUnsupported)
+			assert.ErrorContains(t, err, "LIMIT")
+		}
+		assert.Empty(t, queries)
+	})
+}
+
+func TestPaginated(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM users": "SELECT * FROM users LIMIT $surgo_limit START $surgo_start",
+		"SELECT * FROM users WHERE timeout > 5 ORDER BY limit PARALLEL":   "SELECT * FROM users WHERE timeout > 5 ORDER BY limit LIMIT $surgo_limit START $surgo_start PARALLEL",
+		"SELECT fetch, (SELECT * FROM x LIMIT 1) AS start FROM users":     "SELECT fetch, (SELECT * FROM x LIMIT 1) AS start FROM users LIMIT $surgo_limit START $surgo_start",
+		"SELECT * FROM users WHERE name = 'a FETCH b' EXPLAIN FULL":       "SELECT * FROM users WHERE name = 'a FETCH b' LIMIT $surgo_limit START $surgo_start EXPLAIN FULL",
+		"SELECT * FROM users WHERE user.start = $limit TEMPFILES EXPLAIN": "SELECT * FROM users WHERE user.start = $limit LIMIT $surgo_limit START $surgo_start TEMPFILES EXPLAIN",
+	}
+	for statement, want := range tests {
+		got, err := paginated(statement)
+		assert.NoError(t, err, statement)
+		assert.Equal(t, want, got, statement)
+	}
+
+	for _, statement := range []string{
+		"SELECT * FROM users LIMIT 10",
+		"SELECT * FROM users START 5 FETCH friends",
+		"select * from users order by name limit 1",
+	} {
+		_, err := paginated(statement)
+		assert.ErrorIs(t, err, errs.ErrUnsupported, statement)
+	}
+}
+
+func TestPaginateByID(t *testing.T) {
+	var queries []string
+	var vars []map[string]any
+	db := newTestDB(t, recordQueries(&queries, &vars, pages(
+		map[string]any{"id": "users:a", "name": "A"},
+		map[string]any{"id": "users:b", "name": "B"},
+	)))
+
+	var names []string
+	for user, err := range PaginateByID[testUser](db, "users", qb.Eq("active", true), 1) {
+		assert.NoError(t, err)
+		names = append(names, user.Name)
+	}
+	assert.Equal(t, []string{"A", "B"}, names)
+	assert.Equal(t, []string{
+		"SELECT * FROM users WHERE active = $p1 ORDER BY id ASC LIMIT $p2",
+		"SELECT * FROM users WHERE (active = $p1 AND id > <record> $p2) ORDER BY id ASC LIMIT $p3",
+		"SELECT * FROM users WHERE (active = $p1 AND id > <record> $p2) ORDER BY id ASC LIMIT $p3",
+	}, queries)
+}
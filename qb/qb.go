@@ -0,0 +1,215 @@
+// Package qb builds SurrealQL statements. Values are never written into the
+// statement, they are bound to generated vars instead, so that a Query can be
+// passed to DB.Query directly:
+//
+//	db.Query(qb.Select().From("users").Where(qb.Eq("name", name)).Build())
+//
+// Identifiers such as table and field names are written as they are. Dynamic
+// names have to be escaped with sql.Ident or sql.Thing.
+package qb
+
+import (
+	"github.com/NoBypass/surgo/v2/marshal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a SurrealQL statement.
+type Query interface {
+	// Build returns the statement and the vars its values are bound to.
+	Build() (string, map[string]any)
+}
+
+// statement is implemented by the queries of this package. Statements can be
+// used as values, in which case they are written as subqueries.
+type statement interface {
+	Query
+	writeStatement(b *builder)
+}
+
+// builder writes a statement and binds its values to the vars $p1, $p2, ...
+type builder struct {
+	strings.Builder
+	vars map[string]any
+}
+
+func build(s statement) (string, map[string]any) {
+	b := &builder{vars: make(map[string]any)}
+	s.writeStatement(b)
+	return b.String(), b.vars
+}
+
+// param binds v to a new var and returns its name, e.g. $p1.
+func (b *builder) param(v any) string {
+	name := "p" + strconv.Itoa(len(b.vars)+1)
+	b.vars[name] = v
+	return "$" + name
+}
+
+// value writes v, which is either an expression, a subquery or a value bound
+// to a var.
+func (b *builder) value(v any) {
+	switch v := v.(type) {
+	case Cond:
+		v.writeTo(b)
+	case statement:
+		b.WriteString("(")
+		v.writeStatement(b)
+		b.WriteString(")")
+	default:
+		b.WriteString(b.param(v))
+	}
+}
+
+// list writes the clause with the comma separated items unless there are none.
+func (b *builder) list(clause string, items []string) {
+	if len(items) > 0 {
+		b.WriteString(" " + clause + " " + strings.Join(items, ", "))
+	}
+}
+
+func (b *builder) where(c Cond) {
+	if c != nil {
+		b.WriteString(" WHERE ")
+		c.writeTo(b)
+	}
+}
+
+func (b *builder) timeout(d time.Duration) {
+	if d > 0 {
+		b.WriteString(" TIMEOUT " + marshal.FormatDuration(d))
+	}
+}
+
+// Cond is a condition or an expression, see Expr.
+type Cond interface {
+	writeTo(b *builder)
+}
+
+type cond func(b *builder)
+
+func (c cond) writeTo(b *builder) {
+	c(b)
+}
+
+// Expr is a raw SurrealQL expression. Every ? in it is replaced by the next
+// arg, which is bound to a var. The operators ??, ?: and ?. are left as they
+// are, as are question marks in strings. Expr panics if the number of
+// placeholders and args differ.
+func Expr(expr string, args ...any) Cond {
+	return cond(func(b *builder) {
+		rs := []rune(expr)
+		n := 0
+		for i := 0; i < len(rs); i++ {
+			switch r := rs[i]; {
+			case r == '\'' || r == '"' || r == '`':
+				start := i
+				for i++; i < len(rs) && rs[i] != r; i++ {
+					if rs[i] == '\\' {
+						i++
+					}
+				}
+				b.WriteString(string(rs[start:min(i+1, len(rs))]))
+			case r == '?' && isPlaceholder(rs, i):
+				if n == len(args) {
+					panic("qb: missing arg for placeholder in " + strconv.Quote(expr))
+				}
+				b.value(args[n])
+				n++
+			default:
+				b.WriteRune(r)
+			}
+		}
+		if n != len(args) {
+			panic("qb: too many args for " + strconv.Quote(expr))
+		}
+	})
+}
+
+// isPlaceholder reports whether the ? at i is not part of an operator.
+func isPlaceholder(rs []rune, i int) bool {
+	if i > 0 && rs[i-1] == '?' {
+		return false
+	} else if i+1 < len(rs) && (rs[i+1] == '?' || rs[i+1] == ':' || rs[i+1] == '.') {
+		return false
+	}
+	return true
+}
+
+// Eq is field = v.
+func Eq(field string, v any) Cond { return compare(field, "=", v) }
+
+// Ne is field != v.
+func Ne(field string, v any) Cond { return compare(field, "!=", v) }
+
+// Gt is field > v.
+func Gt(field string, v any) Cond { return compare(field, ">", v) }
+
+// Gte is field >= v.
+func Gte(field string, v any) Cond { return compare(field, ">=", v) }
+
+// Lt is field < v.
+func Lt(field string, v any) Cond { return compare(field, "<", v) }
+
+// Lte is field <= v.
+func Lte(field string, v any) Cond { return compare(field, "<=", v) }
+
+// In is field IN v, where v is usually a slice.
+func In(field string, v any) Cond { return compare(field, "IN", v) }
+
+// Contains is field CONTAINS v.
+func Contains(field string, v any) Cond { return compare(field, "CONTAINS", v) }
+
+func compare(field, op string, v any) Cond {
+	return cond(func(b *builder) {
+		b.WriteString(field + " " + op + " ")
+		b.value(v)
+	})
+}
+
+// And joins the conditions with AND. Nil conditions are left out, and if
+// all of them are nil, so is the result.
+func And(conds ...Cond) Cond { return join(" AND ", conds) }
+
+// Or joins the conditions with OR. Like And, it leaves out nil conditions.
+func Or(conds ...Cond) Cond { return join(" OR ", conds) }
+
+func join(op string, conds []Cond) Cond {
+	conds = slices.DeleteFunc(slices.Clone(conds), func(c Cond) bool { return c == nil })
+	if len(conds) == 0 {
+		return nil
+	}
+	return cond(func(b *builder) {
+		if len(conds) > 1 {
+			b.WriteString("(")
+		}
+		for i, c := range conds {
+			if i > 0 {
+				b.WriteString(op)
+			}
+			c.writeTo(b)
+		}
+		if len(conds) > 1 {
+			b.WriteString(")")
+		}
+	})
+}
+
+// and joins c to the current condition. Both may be nil.
+func and(current, c Cond) Cond {
+	return And(current, c)
+}
+
+// Not negates the condition. The negation of a nil condition is nil.
+func Not(c Cond) Cond {
+	if c == nil {
+		return nil
+	}
+	return cond(func(b *builder) {
+		b.WriteString("!(")
+		c.writeTo(b)
+		b.WriteString(")")
+	})
+}
@@ -0,0 +1,146 @@
+package qb
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// assertGolden compares the statement and vars of q with testdata/<name>.golden.
+// The vars are compared the way they are sent to the database.
+func assertGolden(t *testing.T, name string, q Query) {
+	t.Helper()
+
+	query, vars := q.Build()
+	b, err := json.MarshalIndent((&marshal.Codec{}).Marshal(vars), "", "  ")
+	assert.NoError(t, err)
+	got := query + "\n-- vars --\n" + string(b) + "\n"
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, []byte(got), 0o644))
+	}
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), got)
+}
+
+type user struct {
+	Name  string    `db:"name"`
+	Email string    `db:"email"`
+	Born  time.Time `db:"born"`
+}
+
+func TestSelect(t *testing.T) {
+	t.Run("all clauses", func(t *testing.T) {
+		assertGolden(t, "select", Select("name", "count() AS total").
+			From("user").
+			Where(Gte("age", 18)).
+			Where(Or(Eq("country", "CH"), In("country", []string{"DE", "AT"}))).
+			Split("tags").
+			GroupBy("name").
+			OrderByDesc("total").
+			OrderBy("name").
+			Limit(10).
+			Start(20).
+			Fetch("friends").
+			Timeout(5*time.Second).
+			Parallel())
+	})
+	t.Run("value only", func(t *testing.T) {
+		assertGolden(t, "select_value", SelectValue("name").From("user:1").Only().GroupAll())
+	})
+	t.Run("subquery", func(t *testing.T) {
+		assertGolden(t, "select_subquery", Select().
+			From("post").
+			Where(In("author", SelectValue("id").From("user").Where(Eq("banned", false)))).
+			Where(Not(Contains("tags", "draft"))))
+	})
+}
+
+func TestCond(t *testing.T) {
+	t.Run("nil conditions", func(t *testing.T) {
+		query, vars := Select().From("user").Where(Eq("name", "john")).Where(nil).Build()
+		assert.Equal(t, "SELECT * FROM user WHERE name = $p1", query)
+		assert.Equal(t, map[string]any{"p1": "john"}, vars)
+
+		query, _ = Select().From("user").Where(nil).Where(And(Gt("age", 18), nil)).Where(Or(nil, nil)).Build()
+		assert.Equal(t, "SELECT * FROM user WHERE age > $p1", query)
+
+		query, _ = Delete("user").Where(And(nil, Eq("a", 1), nil, Eq("b", 2))).Build()
+		assert.Equal(t, "DELETE user WHERE (a = $p1 AND b = $p2)", query)
+
+		assert.Nil(t, And())
+		assert.Nil(t, Or(nil))
+		assert.Nil(t, Not(nil))
+	})
+	t.Run("nil negation", func(t *testing.T) {
+		assertGolden(t, "select_nil_not", Select().
+			From("user").
+			Where(Not(nil)).
+			Where(And(Not(Eq("banned", true)), Not(nil))))
+	})
+}
+
+func TestExpr(t *testing.T) {
+	t.Run("placeholders", func(t *testing.T) {
+		assertGolden(t, "expr", Select().From("user").Where(
+			Expr("string::lowercase(name) = ? AND nick ?? 'who?' != ? AND a?.b ?: c", "tobie", "x"),
+		))
+	})
+	t.Run("missing arg", func(t *testing.T) {
+		assert.Panics(t, func() { Select().From("user").Where(Expr("a = ? AND b = ?", 1)).Build() })
+	})
+	t.Run("too many args", func(t *testing.T) {
+		assert.Panics(t, func() { Select().From("user").Where(Expr("a = ?", 1, 2)).Build() })
+	})
+}
+
+func TestWrite(t *testing.T) {
+	born := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	t.Run("create", func(t *testing.T) {
+		assertGolden(t, "create", Create("user").
+			Content(user{Name: "Tobie", Email: "tobie@surrealdb.com", Born: born}).
+			Return("NONE"))
+	})
+	t.Run("update", func(t *testing.T) {
+		assertGolden(t, "update", Update("user").
+			Set("name", "Jaime").
+			Set("visits", Expr("visits + ?", 1)).
+			Where(Eq("email", "jaime@surrealdb.com")).
+			Return("AFTER").
+			Timeout(time.Second))
+	})
+	t.Run("upsert", func(t *testing.T) {
+		assertGolden(t, "upsert", Upsert("user:tobie").Only().Merge(map[string]any{"active": true}))
+	})
+	t.Run("content replaces set", func(t *testing.T) {
+		query, vars := Update("user:1").Set("name", "a").Content(map[string]any{"name": "b"}).Build()
+		assert.Equal(t, "UPDATE user:1 CONTENT $p1", query)
+		assert.Equal(t, map[string]any{"p1": map[string]any{"name": "b"}}, vars)
+	})
+	t.Run("delete", func(t *testing.T) {
+		assertGolden(t, "delete", Delete("session").Where(Lt("expires", born)).Return("BEFORE"))
+	})
+	t.Run("relate", func(t *testing.T) {
+		assertGolden(t, "relate", Relate("user:tobie", "wrote", "post:1").Set("at", born))
+	})
+	t.Run("insert", func(t *testing.T) {
+		assertGolden(t, "insert", Insert("user").
+			Values(user{Name: "Tobie"}, user{Name: "Jaime"}).
+			OnDuplicate("visits", Expr("visits + ?", 1)))
+	})
+	t.Run("insert relation", func(t *testing.T) {
+		assertGolden(t, "insert_relation", InsertRelation("likes").
+			Ignore().
+			Values(map[string]any{"in": "user:1", "out": "post:1"}))
+	})
+}
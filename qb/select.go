@@ -0,0 +1,156 @@
+package qb
+
+import (
+	"strings"
+	"time"
+)
+
+// SelectQuery is a SELECT statement, see Select.
+type SelectQuery struct {
+	fields   []string
+	value    bool
+	targets  []string
+	only     bool
+	where    Cond
+	split    []string
+	groupBy  []string
+	groupAll bool
+	orderBy  []string
+	limit    *int
+	start    *int
+	fetch    []string
+	timeout  time.Duration
+	parallel bool
+}
+
+// Select starts a SELECT statement of the given fields. Without fields, all
+// fields are selected.
+func Select(fields ...string) *SelectQuery {
+	return &SelectQuery{fields: fields}
+}
+
+// SelectValue starts a SELECT VALUE statement, which returns the values of the
+// field instead of objects.
+func SelectValue(field string) *SelectQuery {
+	return &SelectQuery{fields: []string{field}, value: true}
+}
+
+// From sets the tables or record ids to select from.
+func (q *SelectQuery) From(targets ...string) *SelectQuery {
+	q.targets = append(q.targets, targets...)
+	return q
+}
+
+// Only selects a single record instead of an array of records.
+func (q *SelectQuery) Only() *SelectQuery {
+	q.only = true
+	return q
+}
+
+// Where sets the condition. Multiple calls are joined with AND, nil
+// conditions are ignored.
+func (q *SelectQuery) Where(c Cond) *SelectQuery {
+	q.where = and(q.where, c)
+	return q
+}
+
+// Split splits the results by the values of the fields.
+func (q *SelectQuery) Split(fields ...string) *SelectQuery {
+	q.split = append(q.split, fields...)
+	return q
+}
+
+// GroupBy groups the results by the fields.
+func (q *SelectQuery) GroupBy(fields ...string) *SelectQuery {
+	q.groupBy = append(q.groupBy, fields...)
+	return q
+}
+
+// GroupAll groups all results into one.
+func (q *SelectQuery) GroupAll() *SelectQuery {
+	q.groupAll = true
+	return q
+}
+
+// OrderBy orders the results ascending by the field.
+func (q *SelectQuery) OrderBy(field string) *SelectQuery {
+	q.orderBy = append(q.orderBy, field+" ASC")
+	return q
+}
+
+// OrderByDesc orders the results descending by the field.
+func (q *SelectQuery) OrderByDesc(field string) *SelectQuery {
+	q.orderBy = append(q.orderBy, field+" DESC")
+	return q
+}
+
+// Limit limits the number of results.
+func (q *SelectQuery) Limit(n int) *SelectQuery {
+	q.limit = &n
+	return q
+}
+
+// Start skips the first n results.
+func (q *SelectQuery) Start(n int) *SelectQuery {
+	q.start = &n
+	return q
+}
+
+// Fetch fetches the records the fields link to.
+func (q *SelectQuery) Fetch(fields ...string) *SelectQuery {
+	q.fetch = append(q.fetch, fields...)
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *SelectQuery) Timeout(d time.Duration) *SelectQuery {
+	q.timeout = d
+	return q
+}
+
+// Parallel fetches the records in parallel.
+func (q *SelectQuery) Parallel() *SelectQuery {
+	q.parallel = true
+	return q
+}
+
+// Build implements Query.
+func (q *SelectQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *SelectQuery) writeStatement(b *builder) {
+	b.WriteString("SELECT ")
+	if q.value {
+		b.WriteString("VALUE ")
+	}
+	if len(q.fields) == 0 {
+		b.WriteString("*")
+	} else {
+		b.WriteString(strings.Join(q.fields, ", "))
+	}
+	if q.only {
+		b.list("FROM ONLY", q.targets)
+	} else {
+		b.list("FROM", q.targets)
+	}
+	b.where(q.where)
+	b.list("SPLIT", q.split)
+	if q.groupAll {
+		b.WriteString(" GROUP ALL")
+	} else {
+		b.list("GROUP BY", q.groupBy)
+	}
+	b.list("ORDER BY", q.orderBy)
+	if q.limit != nil {
+		b.WriteString(" LIMIT " + b.param(*q.limit))
+	}
+	if q.start != nil {
+		b.WriteString(" START " + b.param(*q.start))
+	}
+	b.list("FETCH", q.fetch)
+	b.timeout(q.timeout)
+	if q.parallel {
+		b.WriteString(" PARALLEL")
+	}
+}
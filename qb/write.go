@@ -0,0 +1,388 @@
+package qb
+
+import (
+	"strings"
+	"time"
+)
+
+// data is the data clause of a statement. CONTENT, MERGE and SET replace
+// each other.
+type data struct {
+	clause  string
+	content any
+	sets    []assignment
+}
+
+type assignment struct {
+	field string
+	value any
+}
+
+func (d *data) replace(clause string, v any) {
+	d.clause, d.content, d.sets = clause, v, nil
+}
+
+func (d *data) set(field string, v any) {
+	if d.clause != "SET" {
+		d.clause, d.content = "SET", nil
+	}
+	d.sets = append(d.sets, assignment{field, v})
+}
+
+func (d *data) writeTo(b *builder) {
+	switch d.clause {
+	case "":
+	case "SET":
+		writeAssignments(b, " SET ", d.sets)
+	default:
+		b.WriteString(" " + d.clause + " ")
+		b.value(d.content)
+	}
+}
+
+func writeAssignments(b *builder, clause string, sets []assignment) {
+	b.WriteString(clause)
+	for i, s := range sets {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.field + " = ")
+		b.value(s.value)
+	}
+}
+
+// output holds the RETURN and TIMEOUT clauses shared by all write statements.
+type output struct {
+	returns []string
+	timeout time.Duration
+}
+
+func (o *output) writeTo(b *builder) {
+	b.list("RETURN", o.returns)
+	b.timeout(o.timeout)
+}
+
+func writeTargets(b *builder, only bool, targets []string) {
+	if only {
+		b.WriteString("ONLY ")
+	}
+	b.WriteString(strings.Join(targets, ", "))
+}
+
+// CreateQuery is a CREATE statement, see Create.
+type CreateQuery struct {
+	targets []string
+	only    bool
+	data    data
+	output  output
+}
+
+// Create starts a CREATE statement for the tables or record ids.
+func Create(targets ...string) *CreateQuery {
+	return &CreateQuery{targets: targets}
+}
+
+// Only returns a single record instead of an array of records.
+func (q *CreateQuery) Only() *CreateQuery {
+	q.only = true
+	return q
+}
+
+// Content sets the content of the record to v.
+func (q *CreateQuery) Content(v any) *CreateQuery {
+	q.data.replace("CONTENT", v)
+	return q
+}
+
+// Set sets the field to v, which can also be an expression.
+func (q *CreateQuery) Set(field string, v any) *CreateQuery {
+	q.data.set(field, v)
+	return q
+}
+
+// Return sets what is returned, e.g. NONE, BEFORE, AFTER, DIFF or fields.
+func (q *CreateQuery) Return(fields ...string) *CreateQuery {
+	q.output.returns = fields
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *CreateQuery) Timeout(d time.Duration) *CreateQuery {
+	q.output.timeout = d
+	return q
+}
+
+// Build implements Query.
+func (q *CreateQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *CreateQuery) writeStatement(b *builder) {
+	b.WriteString("CREATE ")
+	writeTargets(b, q.only, q.targets)
+	q.data.writeTo(b)
+	q.output.writeTo(b)
+}
+
+// UpdateQuery is an UPDATE or UPSERT statement, see Update and Upsert.
+type UpdateQuery struct {
+	keyword string
+	targets []string
+	only    bool
+	data    data
+	where   Cond
+	output  output
+}
+
+// Update starts an UPDATE statement for the tables or record ids.
+func Update(targets ...string) *UpdateQuery {
+	return &UpdateQuery{keyword: "UPDATE", targets: targets}
+}
+
+// Upsert starts an UPSERT statement for the tables or record ids. Records
+// which do not exist are created.
+func Upsert(targets ...string) *UpdateQuery {
+	return &UpdateQuery{keyword: "UPSERT", targets: targets}
+}
+
+// Only returns a single record instead of an array of records.
+func (q *UpdateQuery) Only() *UpdateQuery {
+	q.only = true
+	return q
+}
+
+// Content replaces the content of the records with v.
+func (q *UpdateQuery) Content(v any) *UpdateQuery {
+	q.data.replace("CONTENT", v)
+	return q
+}
+
+// Merge merges v into the records.
+func (q *UpdateQuery) Merge(v any) *UpdateQuery {
+	q.data.replace("MERGE", v)
+	return q
+}
+
+// Set sets the field to v, which can also be an expression.
+func (q *UpdateQuery) Set(field string, v any) *UpdateQuery {
+	q.data.set(field, v)
+	return q
+}
+
+// Where sets the condition. Multiple calls are joined with AND, nil
+// conditions are ignored.
+func (q *UpdateQuery) Where(c Cond) *UpdateQuery {
+	q.where = and(q.where, c)
+	return q
+}
+
+// Return sets what is returned, e.g. NONE, BEFORE, AFTER, DIFF or fields.
+func (q *UpdateQuery) Return(fields ...string) *UpdateQuery {
+	q.output.returns = fields
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *UpdateQuery) Timeout(d time.Duration) *UpdateQuery {
+	q.output.timeout = d
+	return q
+}
+
+// Build implements Query.
+func (q *UpdateQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *UpdateQuery) writeStatement(b *builder) {
+	b.WriteString(q.keyword + " ")
+	writeTargets(b, q.only, q.targets)
+	q.data.writeTo(b)
+	b.where(q.where)
+	q.output.writeTo(b)
+}
+
+// DeleteQuery is a DELETE statement, see Delete.
+type DeleteQuery struct {
+	targets []string
+	only    bool
+	where   Cond
+	output  output
+}
+
+// Delete starts a DELETE statement for the tables or record ids.
+func Delete(targets ...string) *DeleteQuery {
+	return &DeleteQuery{targets: targets}
+}
+
+// Only returns a single record instead of an array of records.
+func (q *DeleteQuery) Only() *DeleteQuery {
+	q.only = true
+	return q
+}
+
+// Where sets the condition. Multiple calls are joined with AND, nil
+// conditions are ignored.
+func (q *DeleteQuery) Where(c Cond) *DeleteQuery {
+	q.where = and(q.where, c)
+	return q
+}
+
+// Return sets what is returned, e.g. NONE, BEFORE, AFTER, DIFF or fields.
+func (q *DeleteQuery) Return(fields ...string) *DeleteQuery {
+	q.output.returns = fields
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *DeleteQuery) Timeout(d time.Duration) *DeleteQuery {
+	q.output.timeout = d
+	return q
+}
+
+// Build implements Query.
+func (q *DeleteQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *DeleteQuery) writeStatement(b *builder) {
+	b.WriteString("DELETE ")
+	writeTargets(b, q.only, q.targets)
+	b.where(q.where)
+	q.output.writeTo(b)
+}
+
+// RelateQuery is a RELATE statement, see Relate.
+type RelateQuery struct {
+	from, edge, to string
+	only           bool
+	data           data
+	output         output
+}
+
+// Relate starts a RELATE statement which creates an edge of the edge table
+// between the records from and to.
+func Relate(from, edge, to string) *RelateQuery {
+	return &RelateQuery{from: from, edge: edge, to: to}
+}
+
+// Only returns a single edge instead of an array of edges.
+func (q *RelateQuery) Only() *RelateQuery {
+	q.only = true
+	return q
+}
+
+// Content sets the content of the edge to v.
+func (q *RelateQuery) Content(v any) *RelateQuery {
+	q.data.replace("CONTENT", v)
+	return q
+}
+
+// Set sets the field of the edge to v, which can also be an expression.
+func (q *RelateQuery) Set(field string, v any) *RelateQuery {
+	q.data.set(field, v)
+	return q
+}
+
+// Return sets what is returned, e.g. NONE, BEFORE, AFTER, DIFF or fields.
+func (q *RelateQuery) Return(fields ...string) *RelateQuery {
+	q.output.returns = fields
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *RelateQuery) Timeout(d time.Duration) *RelateQuery {
+	q.output.timeout = d
+	return q
+}
+
+// Build implements Query.
+func (q *RelateQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *RelateQuery) writeStatement(b *builder) {
+	b.WriteString("RELATE ")
+	if q.only {
+		b.WriteString("ONLY ")
+	}
+	b.WriteString(q.from + "->" + q.edge + "->" + q.to)
+	q.data.writeTo(b)
+	q.output.writeTo(b)
+}
+
+// InsertQuery is an INSERT statement, see Insert.
+type InsertQuery struct {
+	table       string
+	relation    bool
+	ignore      bool
+	values      []any
+	onDuplicate []assignment
+	output      output
+}
+
+// Insert starts an INSERT statement into the table.
+func Insert(table string) *InsertQuery {
+	return &InsertQuery{table: table}
+}
+
+// InsertRelation starts an INSERT RELATION statement into the edge table. The
+// values must have in and out fields.
+func InsertRelation(table string) *InsertQuery {
+	return &InsertQuery{table: table, relation: true}
+}
+
+// Values adds records to insert.
+func (q *InsertQuery) Values(values ...any) *InsertQuery {
+	q.values = append(q.values, values...)
+	return q
+}
+
+// Ignore skips records which already exist instead of failing.
+func (q *InsertQuery) Ignore() *InsertQuery {
+	q.ignore = true
+	return q
+}
+
+// OnDuplicate sets the field of records which already exist to v, which can
+// also be an expression.
+func (q *InsertQuery) OnDuplicate(field string, v any) *InsertQuery {
+	q.onDuplicate = append(q.onDuplicate, assignment{field, v})
+	return q
+}
+
+// Return sets what is returned, e.g. NONE, BEFORE, AFTER, DIFF or fields.
+func (q *InsertQuery) Return(fields ...string) *InsertQuery {
+	q.output.returns = fields
+	return q
+}
+
+// Timeout cancels the statement on the server after d.
+func (q *InsertQuery) Timeout(d time.Duration) *InsertQuery {
+	q.output.timeout = d
+	return q
+}
+
+// Build implements Query.
+func (q *InsertQuery) Build() (string, map[string]any) {
+	return build(q)
+}
+
+func (q *InsertQuery) writeStatement(b *builder) {
+	b.WriteString("INSERT ")
+	if q.relation {
+		b.WriteString("RELATION ")
+	}
+	if q.ignore {
+		b.WriteString("IGNORE ")
+	}
+	b.WriteString("INTO " + q.table + " ")
+	if len(q.values) == 1 {
+		b.value(q.values[0])
+	} else {
+		b.value(q.values)
+	}
+	if len(q.onDuplicate) > 0 {
+		writeAssignments(b, " ON DUPLICATE KEY UPDATE ", q.onDuplicate)
+	}
+	q.output.writeTo(b)
+}
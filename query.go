@@ -3,7 +3,9 @@ package surgo
 import (
 	"context"
 	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
 	"iter"
+	"time"
 )
 
 const scanCtxKey = "surgo:is_scan"
@@ -14,28 +16,53 @@ type (
 		Queries []Query
 	}
 	Query struct {
+		// Result is the raw result of the statement. Numbers in it are
+		// json.Number values, not float64, so that they keep their precision.
 		Result any
 		Error  error
+		// Status is the status of the statement, OK or ERR.
+		Status string
+		// Duration is the time SurrealDB took to execute the statement.
+		Duration time.Duration
+
+		// codec is the Codec of the DB which produced the query.
+		codec *marshal.Codec
 	}
 )
 
 // Query executes the query and returns the results. The error is
 // only not nil if the whole call failed. If a query fails, the error
-// is stored in the result struct.
-func (db *DB) Query(query string, vars map[string]any) (result *Result) {
+// is stored in the result struct. vars is either a map with string keys,
+// e.g. Vars, or a struct whose fields become the vars.
+func (db *DB) Query(query string, vars any) *Result {
+	return db.query("", query, vars)
+}
+
+// query executes the query in the interactive transaction txn or outside of
+// a transaction if txn is empty.
+func (db *DB) query(txn, query string, vars any) (result *Result) {
 	ctx, cancel := context.WithTimeout(safeContext(db.ctx), db.timeout)
 	defer cancel()
 
 	db.logger.Trace(ctx, TraceQuery, query)
-	vars = db.Marshaler.Marshal(vars)
-	db.logger.Trace(ctx, TraceVars, vars)
 	if ctx.Value(scanCtxKey) == nil {
 		defer func() {
 			db.logger.Trace(ctx, TraceEnd, result)
 		}()
 	}
 
-	res, err := db.Conn.Send(ctx, "query", []any{query, vars})
+	marshaled, err := db.codec().MarshalVars(vars)
+	if err != nil {
+		return &Result{Error: err}
+	}
+	db.logger.Trace(ctx, TraceVars, marshaled)
+	if db.varCheck {
+		if err := CheckVars(query, marshaled); err != nil {
+			return &Result{Error: err}
+		}
+	}
+
+	res, err := db.Conn.SendTx(ctx, txn, "query", []any{query, marshaled})
 	if err != nil {
 		return &Result{Error: err}
 	}
@@ -43,6 +70,10 @@ func (db *DB) Query(query string, vars map[string]any) (result *Result) {
 	db.logger.Trace(ctx, TraceResponse, res)
 
 	queries, err := resultsToQuery(res.([]any))
+	for i := range queries {
+		queries[i].codec = db.codec()
+		db.logger.Trace(ctx, TraceStatement, queries[i])
+	}
 	return &Result{
 		Error:   err,
 		Queries: queries,
@@ -53,7 +84,72 @@ func (db *DB) Query(query string, vars map[string]any) (result *Result) {
 // If multiple results are expected, a pointer to a slice of structs or maps can be passed.
 // NOTE: Only the last result (the last query if multiple are present) is scanned into the
 // given object. If any of the queries fail, the error is returned.
-func (db DB) Scan(dest any, query string, vars map[string]any) (err error) {
+func (db DB) Scan(dest any, query string, vars any) error {
+	return db.scan(query, vars, func(result *Result) error {
+		return result.ScanLast(dest)
+	})
+}
+
+// ScanAll executes the query and scans the result of the i-th statement into
+// the i-th dest. A nil dest skips its statement, which is useful for
+// statements such as LET which have no result. Statements after the last dest
+// are ignored. If any of the scanned statements fail, the error is returned.
+func (db DB) ScanAll(query string, vars any, dests ...any) error {
+	return db.scan(query, vars, func(result *Result) error {
+		return result.scanAll(dests)
+	})
+}
+
+// QueryAs executes the query and decodes the records returned by the last
+// statement. A single record, e.g. from SELECT ... FROM ONLY, results in a
+// slice of length one.
+func QueryAs[T any](db *DB, query string, vars any) (res []T, err error) {
+	err = db.scan(query, vars, func(result *Result) error {
+		queryResult, err := result.Last()
+		if err != nil {
+			return err
+		}
+
+		return db.codec().Unmarshal(records(queryResult), &res)
+	})
+	return res, err
+}
+
+// QueryOne executes the query and decodes the first record returned by the
+// last statement. If there is none, errs.ErrNoResult is returned.
+func QueryOne[T any](db *DB, query string, vars any) (res T, err error) {
+	err = db.scan(query, vars, func(result *Result) error {
+		queryResult, err := result.Last()
+		if err != nil {
+			return err
+		}
+
+		rs := records(queryResult)
+		if len(rs) == 0 {
+			return errs.ErrNoResult
+		}
+		return db.codec().Unmarshal(rs[0], &res)
+	})
+	return res, err
+}
+
+// QueryAll executes the query and decodes the result of every statement into
+// a T, e.g. the counts of several SELECT count() ... GROUP ALL statements. If
+// any of the statements fail, the error is returned.
+func QueryAll[T any](db *DB, query string, vars any) (res []T, err error) {
+	err = db.scan(query, vars, func(result *Result) error {
+		res = make([]T, len(result.Queries))
+		dests := make([]any, len(res))
+		for i := range res {
+			dests[i] = &res[i]
+		}
+		return result.scanAll(dests)
+	})
+	return res, err
+}
+
+// scan executes the query like Scan but passes the result to fn.
+func (db DB) scan(query string, vars any, fn func(*Result) error) (err error) {
 	db.ctx = context.WithValue(safeContext(db.ctx), scanCtxKey, true)
 	defer func() {
 		db.logger.Trace(db.ctx, TraceEnd, err)
@@ -63,13 +159,31 @@ func (db DB) Scan(dest any, query string, vars map[string]any) (err error) {
 	if result.Error != nil {
 		return result.Error
 	}
+	return fn(result)
+}
 
-	queryResult, err := result.Last()
-	if err != nil {
-		return err
+func (r *Result) scanAll(dests []any) error {
+	if len(dests) > len(r.Queries) {
+		return errs.ErrOutOfBounds.Withf("%d destinations for %d statements", len(dests), len(r.Queries))
 	}
 
-	return db.Marshaler.Unmarshal(queryResult, dest)
+	for i, dest := range dests {
+		if dest == nil {
+			continue
+		} else if err := r.Queries[i].Scan(dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records returns the records of a statement result, which is either an
+// array of records or a single one.
+func records(res any) []any {
+	if rs, ok := res.([]any); ok {
+		return rs
+	}
+	return []any{res}
 }
 
 // First returns the result of the first query. If the query failed, the error is returned.
@@ -117,3 +231,59 @@ func (r *Result) Iter() iter.Seq2[any, error] {
 		}
 	}
 }
+
+// TotalDuration returns the sum of the execution times of all queries.
+func (r *Result) TotalDuration() time.Duration {
+	var d time.Duration
+	for _, q := range r.Queries {
+		d += q.Duration
+	}
+	return d
+}
+
+// ScanFirst scans the result of the first query into dest using the
+// Marshaler of the DB. If the query failed, the error is returned.
+func (r *Result) ScanFirst(dest any) error {
+	if r.Error != nil {
+		return r.Error
+	} else if len(r.Queries) == 0 {
+		return errs.ErrNoResult
+	}
+	return r.Queries[0].Scan(dest)
+}
+
+// ScanLast scans the result of the last query into dest using the Marshaler
+// of the DB. If the query failed, the error is returned.
+func (r *Result) ScanLast(dest any) error {
+	if r.Error != nil {
+		return r.Error
+	} else if len(r.Queries) == 0 {
+		return errs.ErrNoResult
+	}
+	return r.Queries[len(r.Queries)-1].Scan(dest)
+}
+
+// ScanAt scans the result of the query at the given index into dest using
+// the Marshaler of the DB. If the query failed, the error is returned.
+func (r *Result) ScanAt(i int, dest any) error {
+	if r.Error != nil {
+		return r.Error
+	} else if i < 0 || i >= len(r.Queries) {
+		return errs.ErrOutOfBounds
+	}
+	return r.Queries[i].Scan(dest)
+}
+
+// Scan scans the result of the query into dest using the Marshaler of the
+// DB. If the query failed, the error is returned.
+func (q Query) Scan(dest any) error {
+	if q.Error != nil {
+		return q.Error
+	}
+
+	m := q.codec
+	if m == nil {
+		m = &marshal.Codec{}
+	}
+	return m.Unmarshal(q.Result, dest)
+}
@@ -0,0 +1,207 @@
+package surgo
+
+import (
+	"context"
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestQueryAs(t *testing.T) {
+	john := map[string]any{"id": "users:john", "name": "John"}
+	jane := map[string]any{"id": "users:jane", "name": "Jane"}
+
+	t.Run("records of the last statement", func(t *testing.T) {
+		db := newTestDB(t, statements(nil, []any{john, jane}))
+		users, err := QueryAs[testUser](db, "LET $a = 1; SELECT * FROM users", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []testUser{{"users:john", "John"}, {"users:jane", "Jane"}}, users)
+	})
+	t.Run("single record", func(t *testing.T) {
+		db := newTestDB(t, statements(john))
+		users, err := QueryAs[testUser](db, "SELECT * FROM ONLY users:john", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []testUser{{"users:john", "John"}}, users)
+	})
+	t.Run("failed statement", func(t *testing.T) {
+		db := newTestDB(t, statements(errors.New("table not found")))
+		_, err := QueryAs[testUser](db, "SELECT * FROM users", nil)
+		assert.ErrorIs(t, err, errs.ErrDatabase)
+	})
+}
+
+func TestQueryOne(t *testing.T) {
+	t.Run("first record", func(t *testing.T) {
+		db := newTestDB(t, statements([]any{map[string]any{"id": "users:john", "name": "John"}}))
+		user, err := QueryOne[testUser](db, "SELECT * FROM users LIMIT 1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+	})
+	t.Run("no records", func(t *testing.T) {
+		db := newTestDB(t, statements([]any{}))
+		_, err := QueryOne[testUser](db, "SELECT * FROM users LIMIT 1", nil)
+		assert.ErrorIs(t, err, errs.ErrNoResult)
+	})
+}
+
+func TestQueryAll(t *testing.T) {
+	db := newTestDB(t, statements(3, 5))
+	counts, err := QueryAll[int](db, "RETURN 3; RETURN 5", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, counts)
+}
+
+func TestDB_ScanAll(t *testing.T) {
+	john := map[string]any{"id": "users:john", "name": "John"}
+
+	t.Run("statement per dest", func(t *testing.T) {
+		db := newTestDB(t, statements(nil, john, []any{john, john}, 2))
+		var user testUser
+		var users []testUser
+		err := db.ScanAll("LET $id = users:john; SELECT * FROM ONLY $id; SELECT * FROM users; RETURN 2", nil, nil, &user, &users)
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+		assert.Len(t, users, 2)
+	})
+	t.Run("failed statement", func(t *testing.T) {
+		db := newTestDB(t, statements(john, errors.New("permission denied")))
+		var a, b testUser
+		err := db.ScanAll("SELECT * FROM ONLY users:john; SELECT * FROM ONLY users:jane", nil, &a, &b)
+		assert.ErrorIs(t, err, errs.ErrDatabase)
+	})
+	t.Run("too many dests", func(t *testing.T) {
+		db := newTestDB(t, statements(john))
+		var a, b testUser
+		err := db.ScanAll("SELECT * FROM ONLY users:john", nil, &a, &b)
+		assert.ErrorIs(t, err, errs.ErrOutOfBounds)
+	})
+}
+
+func TestResult_Scan(t *testing.T) {
+	type jsonUser struct {
+		Name string `json:"name"`
+	}
+	john := map[string]any{"name": "John"}
+	db := newTestDB(t, statements(john, errors.New("permission denied"), []any{john}), WithFallbackTag("json"))
+	result := db.Query("SELECT * FROM ONLY users:john; SELECT * FROM secrets; SELECT * FROM users", nil)
+
+	t.Run("uses the marshaler of the db", func(t *testing.T) {
+		var user jsonUser
+		assert.NoError(t, result.ScanFirst(&user))
+		assert.Equal(t, "John", user.Name)
+
+		var users []jsonUser
+		assert.NoError(t, result.ScanLast(&users))
+		assert.Equal(t, []jsonUser{{"John"}}, users)
+	})
+	t.Run("partially failed result", func(t *testing.T) {
+		var user jsonUser
+		assert.ErrorIs(t, result.ScanAt(1, &user), errs.ErrDatabase)
+		assert.ErrorIs(t, result.ScanAt(3, &user), errs.ErrOutOfBounds)
+
+		var scanned int
+		for _, q := range result.Queries {
+			if q.Error == nil {
+				var v any
+				assert.NoError(t, q.Scan(&v))
+				scanned++
+			}
+		}
+		assert.Equal(t, 2, scanned)
+	})
+	t.Run("failed call", func(t *testing.T) {
+		r := &Result{Error: errs.ErrNoConnection}
+		var v any
+		assert.ErrorIs(t, r.ScanFirst(&v), errs.ErrNoConnection)
+		assert.ErrorIs(t, r.ScanLast(&v), errs.ErrNoConnection)
+		assert.ErrorIs(t, (&Result{}).ScanFirst(&v), errs.ErrNoResult)
+	})
+}
+
+type traceRecorder struct {
+	silentLogger
+	traces map[TraceType][]any
+}
+
+func (l *traceRecorder) Trace(_ context.Context, t TraceType, data any) {
+	l.traces[t] = append(l.traces[t], data)
+}
+
+func TestQuery_Duration(t *testing.T) {
+	logger := &traceRecorder{traces: make(map[TraceType][]any)}
+	db := newTestDB(t, func(rpc.Request) (any, *rpc.Error) {
+		return []any{
+			map[string]any{"status": "OK", "result": nil, "time": "26.166µs"},
+			map[string]any{"status": "ERR", "result": "table not found", "time": "1.5ms"},
+			map[string]any{"status": "OK", "result": []any{}},
+		}, nil
+	}, WithLogger(logger))
+
+	result := db.Query("LET $a = 1; SELECT * FROM a; SELECT * FROM b", nil)
+	assert.NoError(t, result.Error)
+	assert.Equal(t, 26166*time.Nanosecond, result.Queries[0].Duration)
+	assert.Equal(t, "OK", result.Queries[0].Status)
+	assert.Equal(t, 1500*time.Microsecond, result.Queries[1].Duration)
+	assert.Equal(t, "ERR", result.Queries[1].Status)
+	assert.Zero(t, result.Queries[2].Duration)
+	assert.Equal(t, 1526166*time.Nanosecond, result.TotalDuration())
+
+	statements := logger.traces[TraceStatement]
+	if assert.Len(t, statements, 3) {
+		assert.Equal(t, "ERR", statements[1].(Query).Status)
+		assert.Equal(t, 1500*time.Microsecond, statements[1].(Query).Duration)
+	}
+}
+
+func TestStatementDuration(t *testing.T) {
+	tests := map[any]time.Duration{
+		"1.5s":     1500 * time.Millisecond,
+		"120.3µs":  120300 * time.Nanosecond,
+		"2ns":      2,
+		"1d":       24 * time.Hour,
+		"invalid":  0,
+		nil:        0,
+		float64(1): 0,
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, statementDuration(in), in)
+	}
+}
+
+func TestDatabaseError(t *testing.T) {
+	t.Run("failed statement", func(t *testing.T) {
+		db := newTestDB(t, statements(nil, errors.New("Database record `users:john` already exists")))
+		result := db.Query("LET $id = users:john; CREATE $id", nil)
+
+		_, err := result.At(1)
+		assert.ErrorIs(t, err, errs.ErrAlreadyExists)
+		var dbErr *errs.DatabaseError
+		if assert.ErrorAs(t, err, &dbErr) {
+			assert.Equal(t, 1, dbErr.Statement)
+			assert.Equal(t, 0, dbErr.Code)
+		}
+	})
+	t.Run("failed request", func(t *testing.T) {
+		db := newTestDB(t, func(rpc.Request) (any, *rpc.Error) {
+			return nil, &rpc.Error{Code: -32000, Message: "There was a problem with authentication: The token has expired"}
+		})
+		result := db.Query("SELECT * FROM users", nil)
+
+		assert.ErrorIs(t, result.Error, errs.ErrAuthExpired)
+		var dbErr *errs.DatabaseError
+		if assert.ErrorAs(t, result.Error, &dbErr) {
+			assert.Equal(t, -1, dbErr.Statement)
+			assert.Equal(t, -32000, dbErr.Code)
+		}
+		var rpcErr *rpc.Error
+		assert.ErrorAs(t, result.Error, &rpcErr)
+	})
+}
@@ -0,0 +1,193 @@
+package surgo
+
+import (
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/qb"
+	"github.com/NoBypass/surgo/v2/sql"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+// Repository reads and writes the records of a table as values of type T.
+// The record id is the field of T named id according to the struct tags,
+// e.g. one tagged with db:"id".
+type Repository[T any] struct {
+	db    *DB
+	table string
+}
+
+// ListOptions sort and limit the records returned by Repository.List.
+type ListOptions struct {
+	// OrderBy is the field the records are sorted by, e.g. name or
+	// address.city. The parts of the field are escaped with sql.Ident, so it
+	// can't be an expression.
+	OrderBy string
+	// Desc sorts the records in descending order.
+	Desc bool
+	// Limit is the maximum number of records, 0 means no limit.
+	Limit int
+	// Start is the number of records which are skipped.
+	Start int
+}
+
+// NewRepository returns a Repository for the table.
+func NewRepository[T any](db *DB, table string) *Repository[T] {
+	return &Repository[T]{db: db, table: table}
+}
+
+// Get returns the record with the id. The id is either the key of the record
+// or the whole record id, e.g. john or users:john. If the record does not
+// exist, errs.ErrNotFound is returned.
+func (r *Repository[T]) Get(id any) (T, error) {
+	target, vars := r.thing(id)
+	res, err := QueryOne[T](r.db, "SELECT * FROM "+target, vars)
+	return res, r.notFound(err, id)
+}
+
+// List returns the records matching the filter, which may be nil to return
+// all records.
+func (r *Repository[T]) List(filter qb.Cond, opts ListOptions) ([]T, error) {
+	q := qb.Select().From(sql.Ident(r.table)).Where(filter)
+	if opts.OrderBy != "" && opts.Desc {
+		q.OrderByDesc(fieldPath(opts.OrderBy))
+	} else if opts.OrderBy != "" {
+		q.OrderBy(fieldPath(opts.OrderBy))
+	}
+	if opts.Limit > 0 {
+		q.Limit(opts.Limit)
+	}
+	if opts.Start > 0 {
+		q.Start(opts.Start)
+	}
+	query, vars := q.Build()
+	return QueryAs[T](r.db, query, vars)
+}
+
+// Create creates the record and returns it as stored by SurrealDB. If the id
+// of v is empty, SurrealDB generates one.
+func (r *Repository[T]) Create(v T) (T, error) {
+	id, content, err := r.content(v)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	if id == nil {
+		return QueryOne[T](r.db, "CREATE type::table($table) CONTENT $content", Vars{"table": r.table, "content": content})
+	}
+	target, vars := r.thing(id)
+	return QueryOne[T](r.db, "CREATE "+target+" CONTENT $content", vars.Set("content", content))
+}
+
+// Update replaces the record with the id of v and returns it. If the record
+// does not exist, errs.ErrNotFound is returned.
+func (r *Repository[T]) Update(v T) (T, error) {
+	id, content, err := r.content(v)
+	if err != nil {
+		var zero T
+		return zero, err
+	} else if id == nil {
+		var zero T
+		return zero, errs.ErrMarshal.Withf("cannot update %T without an id", v)
+	}
+
+	target, vars := r.thing(id)
+	res, err := QueryOne[T](r.db, "UPDATE "+target+" CONTENT $content", vars.Set("content", content))
+	return res, r.notFound(err, id)
+}
+
+// Merge merges patch, a map or a struct, into the record with the id and
+// returns the record. Fields which are absent in patch are kept, see
+// Nullable. If the record does not exist, errs.ErrNotFound is returned.
+func (r *Repository[T]) Merge(id any, patch any) (T, error) {
+	target, vars := r.thing(id)
+	res, err := QueryOne[T](r.db, "UPDATE "+target+" MERGE $patch", vars.Set("patch", patch))
+	return res, r.notFound(err, id)
+}
+
+// Delete deletes the record with the id. Deleting a record which does not
+// exist is not an error.
+func (r *Repository[T]) Delete(id any) error {
+	target, vars := r.thing(id)
+	_, err := r.db.Query("DELETE "+target, vars).Last()
+	if errors.Is(err, errs.ErrNoResult) {
+		return nil
+	}
+	return err
+}
+
+// Count returns the number of records matching the filter, which may be nil
+// to count all records.
+func (r *Repository[T]) Count(filter qb.Cond) (int, error) {
+	type count struct {
+		Count int `db:"count"`
+	}
+	query, vars := qb.Select("count()").From(sql.Ident(r.table)).Where(filter).GroupAll().Build()
+	res, err := QueryOne[count](r.db, query, vars)
+	if errors.Is(err, errs.ErrNoResult) {
+		return 0, nil
+	}
+	return res.Count, err
+}
+
+// Exists reports whether the record with the id exists.
+func (r *Repository[T]) Exists(id any) (bool, error) {
+	target, vars := r.thing(id)
+	ids, err := QueryAs[any](r.db, "SELECT VALUE id FROM "+target, vars)
+	if errors.Is(err, errs.ErrNoResult) {
+		return false, nil
+	}
+	return len(ids) > 0 && ids[0] != nil, err
+}
+
+// Iterate returns the records matching the filter, which may be nil, ordered
+// by their id. The records are fetched in batches of the given size, see
+// PaginateByID.
+func (r *Repository[T]) Iterate(filter qb.Cond, batch int) iter.Seq2[T, error] {
+	return PaginateByID[T](r.db, r.table, filter, batch)
+}
+
+// thing returns the expression of the record with the id and its vars. Whole
+// record ids of the table are cast, as SurrealDB would otherwise use the
+// string as the key.
+func (r *Repository[T]) thing(id any) (string, Vars) {
+	if s, ok := id.(string); ok && strings.HasPrefix(s, r.table+":") {
+		return "<record> $id", Vars{"id": s}
+	}
+	return "type::thing($table, $id)", Vars{"table": r.table, "id": id}
+}
+
+// content returns the id of v and its other fields. A zero id is nil.
+func (r *Repository[T]) content(v T) (any, map[string]any, error) {
+	content, err := r.db.codec().MarshalVars(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, ok := content["id"]
+	delete(content, "id")
+	if !ok || id == nil || reflect.ValueOf(id).IsZero() {
+		return nil, content, nil
+	}
+	return id, content, nil
+}
+
+// notFound replaces errs.ErrNoResult with errs.ErrNotFound.
+func (r *Repository[T]) notFound(err error, id any) error {
+	if errors.Is(err, errs.ErrNoResult) {
+		return errs.ErrNotFound.Withf("record %v of table %s", id, r.table)
+	}
+	return err
+}
+
+// fieldPath escapes the parts of a field path like address.city with
+// sql.Ident.
+func fieldPath(path string) string {
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		parts[i] = sql.Ident(p)
+	}
+	return strings.Join(parts, ".")
+}
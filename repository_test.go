@@ -0,0 +1,216 @@
+package surgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/qb"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordQueries answers every query with h and records the queries and vars.
+func recordQueries(queries *[]string, vars *[]map[string]any, h handlerFunc) handlerFunc {
+	return func(req rpc.Request) (any, *rpc.Error) {
+		*queries = append(*queries, req.Params[0].(string))
+		v, _ := req.Params[1].(map[string]any)
+		*vars = append(*vars, v)
+		return h(req)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	john := map[string]any{"id": "users:john", "name": "John"}
+
+	t.Run("get", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{john}))), "users")
+
+		user, err := users.Get("john")
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+
+		_, err = users.Get("users:john")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"SELECT * FROM type::thing($table, $id)", "SELECT * FROM <record> $id"}, queries)
+		assert.Equal(t, []map[string]any{{"table": "users", "id": "john"}, {"id": "users:john"}}, vars)
+	})
+	t.Run("get missing record", func(t *testing.T) {
+		users := NewRepository[testUser](newTestDB(t, statements([]any{})), "users")
+		_, err := users.Get("jane")
+		assert.ErrorIs(t, err, errs.ErrNotFound)
+	})
+	t.Run("list", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{john}))), "user group")
+
+		res, err := users.List(qb.Eq("name", "John"), ListOptions{OrderBy: "name", Desc: true, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []testUser{{"users:john", "John"}}, res)
+
+		_, err = users.List(nil, ListOptions{})
+		assert.NoError(t, err)
+		_, err = users.List(nil, ListOptions{OrderBy: "address.city"})
+		assert.NoError(t, err)
+		_, err = users.List(nil, ListOptions{OrderBy: "name; DELETE users"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"SELECT * FROM `user group` WHERE name = $p1 ORDER BY name DESC LIMIT $p2",
+			"SELECT * FROM `user group`",
+			"SELECT * FROM `user group` ORDER BY address.city ASC",
+			"SELECT * FROM `user group` ORDER BY `name; DELETE users` ASC",
+		}, queries)
+	})
+	t.Run("create", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{john}))), "users")
+
+		user, err := users.Create(testUser{Name: "John"})
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+
+		_, err = users.Create(testUser{ID: "john", Name: "John"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"CREATE type::table($table) CONTENT $content",
+			"CREATE type::thing($table, $id) CONTENT $content",
+		}, queries)
+		assert.Equal(t, map[string]any{"name": "John"}, vars[0]["content"])
+		assert.Equal(t, map[string]any{"name": "John"}, vars[1]["content"])
+	})
+	t.Run("update", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{}))), "users")
+
+		_, err := users.Update(testUser{Name: "John"})
+		assert.ErrorIs(t, err, errs.ErrMarshal)
+		assert.Empty(t, queries)
+
+		_, err = users.Update(testUser{ID: "users:john", Name: "John"})
+		assert.ErrorIs(t, err, errs.ErrNotFound)
+		assert.Equal(t, []string{"UPDATE <record> $id CONTENT $content"}, queries)
+	})
+	t.Run("merge", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{john}))), "users")
+
+		user, err := users.Merge("john", Vars{"name": "John"})
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+		assert.Equal(t, []string{"UPDATE type::thing($table, $id) MERGE $patch"}, queries)
+		assert.Equal(t, map[string]any{"name": "John"}, vars[0]["patch"])
+	})
+	t.Run("delete", func(t *testing.T) {
+		users := NewRepository[testUser](newTestDB(t, statements([]any{})), "users")
+		assert.NoError(t, users.Delete("john"))
+	})
+	t.Run("count", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, statements([]any{map[string]any{"count": 2}}))), "users")
+
+		n, err := users.Count(qb.Gt("age", 18))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"SELECT count() FROM users WHERE age > $p1 GROUP ALL"}, queries)
+
+		users = NewRepository[testUser](newTestDB(t, statements([]any{})), "users")
+		n, err = users.Count(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+	})
+	t.Run("exists", func(t *testing.T) {
+		users := NewRepository[testUser](newTestDB(t, statements([]any{"users:john"})), "users")
+		ok, err := users.Exists("john")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		users = NewRepository[testUser](newTestDB(t, statements([]any{})), "users")
+		ok, err = users.Exists("jane")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+// pages answers queries with the records after the cursor, which is the only
+// string var, as many at a time as the only number var allows.
+func pages(records ...map[string]any) handlerFunc {
+	return func(req rpc.Request) (any, *rpc.Error) {
+		vars, _ := req.Params[1].(map[string]any)
+
+		var limit int64
+		start := 0
+		for _, v := range vars {
+			switch v := v.(type) {
+			case json.Number:
+				limit, _ = v.Int64()
+			case string:
+				for i, r := range records {
+					if r["id"] == v {
+						start = i + 1
+					}
+				}
+			}
+		}
+		page := records[start:min(start+int(limit), len(records))]
+		res := make([]any, len(page))
+		for i, r := range page {
+			res[i] = r
+		}
+		return statements(res)(req)
+	}
+}
+
+func TestRepository_Iterate(t *testing.T) {
+	records := []map[string]any{
+		{"id": "users:a", "name": "A"},
+		{"id": "users:b", "name": "B"},
+		{"id": "users:c", "name": "C"},
+	}
+
+	t.Run("all batches", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, pages(records...))), "users")
+
+		var names []string
+		for user, err := range users.Iterate(nil, 2) {
+			assert.NoError(t, err)
+			names = append(names, user.Name)
+		}
+		assert.Equal(t, []string{"A", "B", "C"}, names)
+		assert.Equal(t, []string{
+			"SELECT * FROM users ORDER BY id ASC LIMIT $p1",
+			"SELECT * FROM users WHERE id > <record> $p1 ORDER BY id ASC LIMIT $p2",
+		}, queries)
+	})
+	t.Run("break", func(t *testing.T) {
+		var queries []string
+		var vars []map[string]any
+		users := NewRepository[testUser](newTestDB(t, recordQueries(&queries, &vars, pages(records...))), "users")
+
+		for range users.Iterate(nil, 2) {
+			break
+		}
+		assert.Len(t, queries, 1)
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		db := newTestDB(t, pages(records...)).WithContext(ctx)
+
+		var errs []error
+		for _, err := range NewRepository[testUser](db, "users").Iterate(nil, 2) {
+			errs = append(errs, err)
+		}
+		assert.Len(t, errs, 1)
+		assert.True(t, errors.Is(errs[0], context.Canceled))
+	})
+}
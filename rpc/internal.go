@@ -22,7 +22,11 @@ func (c *WebsocketConn) listen() {
 
 func (c *WebsocketConn) receive(msg []byte) {
 	var res Response
-	err := json.Unmarshal(msg, &res)
+	// UseNumber keeps numbers in their textual form, so that large integers
+	// and decimals do not lose precision before they are unmarshaled.
+	decoder := json.NewDecoder(bytes.NewReader(msg))
+	decoder.UseNumber()
+	err := decoder.Decode(&res)
 	if err != nil {
 		c.logger.Error(err)
 		return
@@ -40,6 +44,23 @@ func (c *WebsocketConn) receive(msg []byte) {
 }
 
 func (c *WebsocketConn) Send(ctx context.Context, method string, params []any) (any, error) {
+	return c.send(ctx, &Request{
+		Method: method,
+		Params: params,
+	})
+}
+
+// SendTx is like Send but runs the request in the interactive transaction
+// with the given id.
+func (c *WebsocketConn) SendTx(ctx context.Context, txn string, method string, params []any) (any, error) {
+	return c.send(ctx, &Request{
+		Txn:    txn,
+		Method: method,
+		Params: params,
+	})
+}
+
+func (c *WebsocketConn) send(ctx context.Context, req *Request) (any, error) {
 	ch := make(chan Response)
 	id := rand.String(16)
 
@@ -52,11 +73,7 @@ func (c *WebsocketConn) Send(ctx context.Context, method string, params []any) (
 		c.mu.Unlock()
 	}()
 
-	req := &Request{
-		ID:     id,
-		Method: method,
-		Params: params,
-	}
+	req.ID = id
 
 	buf := new(bytes.Buffer)
 	encoder := json.NewEncoder(buf)
@@ -73,7 +90,7 @@ func (c *WebsocketConn) Send(ctx context.Context, method string, params []any) (
 	select {
 	case res := <-ch:
 		if res.Error != nil {
-			return nil, errs.ErrDatabase.With(res.Error)
+			return nil, errs.NewDatabaseError(res.Error.Message, res.Error.Code, -1, res.Error)
 		}
 		return res.Result, nil
 	case <-ctx.Done():
@@ -4,6 +4,7 @@ package rpc
 type Request struct {
 	ID     string `json:"id"`
 	Async  bool   `json:"async,omitempty"`
+	Txn    string `json:"txn,omitempty"`
 	Method string `json:"method,omitempty"`
 	Params []any  `json:"params,omitempty"`
 }
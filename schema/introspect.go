@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"cmp"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/sql"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// dbInfo is the result of INFO FOR DB.
+type dbInfo struct {
+	Tables map[string]string `db:"tables"`
+}
+
+// tableInfo is the result of INFO FOR TABLE.
+type tableInfo struct {
+	Fields  map[string]string `db:"fields"`
+	Indexes map[string]string `db:"indexes"`
+}
+
+// Introspect reads the schema of the database with INFO FOR DB and INFO FOR
+// TABLE. The tables, fields and indexes are ordered by their names, so nested
+// fields follow the field they are part of. Clauses which are not part of
+// the model, e.g. CHANGEFEED or the type of a table, are left out.
+func Introspect(db *surgo.DB) (*Database, error) {
+	info, err := surgo.QueryOne[dbInfo](db, "INFO FOR DB", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(info.Tables))
+	for name := range info.Tables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if len(names) == 0 {
+		return &Database{}, nil
+	}
+
+	var query strings.Builder
+	for _, name := range names {
+		query.WriteString("INFO FOR TABLE " + sql.Ident(name) + ";\n")
+	}
+	infos, err := surgo.QueryAll[tableInfo](db, query.String(), nil)
+	if err != nil {
+		return nil, err
+	} else if len(infos) != len(names) {
+		return nil, errs.ErrUnmarshal.Withf("%d results for %d tables", len(infos), len(names))
+	}
+
+	d := &Database{Tables: make([]Table, len(names))}
+	for i, name := range names {
+		t, err := parseTable(info.Tables[name], infos[i])
+		if err != nil {
+			return nil, err
+		}
+		d.Tables[i] = t
+	}
+	return d, nil
+}
+
+func parseTable(definition string, info tableInfo) (Table, error) {
+	name, clauses, err := parseDefine(definition, "TABLE", "DROP", "SCHEMAFULL", "SCHEMALESS", "TYPE", "AS", "CHANGEFEED", "PERMISSIONS", "COMMENT")
+	if err != nil {
+		return Table{}, err
+	}
+	t := Table{
+		Name:        name,
+		Permissions: clauses["PERMISSIONS"],
+		Comment:     unquote(clauses["COMMENT"]),
+	}
+	_, t.Schemafull = clauses["SCHEMAFULL"]
+
+	for _, def := range info.Fields {
+		f, err := parseField(def)
+		if err != nil {
+			return Table{}, err
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	slices.SortFunc(t.Fields, func(a, b Field) int { return cmp.Compare(a.Name, b.Name) })
+
+	for _, def := range info.Indexes {
+		idx, err := parseIndex(def)
+		if err != nil {
+			return Table{}, err
+		}
+		t.Indexes = append(t.Indexes, idx)
+	}
+	slices.SortFunc(t.Indexes, func(a, b Index) int { return cmp.Compare(a.Name, b.Name) })
+	return t, nil
+}
+
+func parseField(definition string) (Field, error) {
+	name, clauses, err := parseDefine(definition, "FIELD", "FLEXIBLE", "TYPE", "DEFAULT", "READONLY", "VALUE", "ASSERT", "PERMISSIONS", "COMMENT", "REFERENCE")
+	if err != nil {
+		return Field{}, err
+	}
+	f := Field{
+		Name:        name,
+		Type:        clauses["TYPE"],
+		Default:     clauses["DEFAULT"],
+		Value:       clauses["VALUE"],
+		Assert:      clauses["ASSERT"],
+		Permissions: clauses["PERMISSIONS"],
+		Comment:     unquote(clauses["COMMENT"]),
+	}
+	_, f.Flexible = clauses["FLEXIBLE"]
+	_, f.Readonly = clauses["READONLY"]
+	return f, nil
+}
+
+func parseIndex(definition string) (Index, error) {
+	name, clauses, err := parseDefine(definition, "INDEX", "FIELDS", "COLUMNS", "UNIQUE", "SEARCH", "MTREE", "HNSW", "COMMENT", "CONCURRENTLY")
+	if err != nil {
+		return Index{}, err
+	}
+	idx := Index{Name: name}
+	_, idx.Unique = clauses["UNIQUE"]
+	for _, f := range splitTopLevel(cmp.Or(clauses["FIELDS"], clauses["COLUMNS"]), ',') {
+		idx.Fields = append(idx.Fields, unescapePath(strings.TrimSpace(f)))
+	}
+	return idx, nil
+}
+
+// parseDefine parses a DEFINE statement of the kind into the name of the
+// defined resource and its clauses by keyword. Keywords are only recognized
+// outside of strings and brackets. Words between the name and the first
+// keyword, e.g. ON table, are skipped.
+func parseDefine(definition, kind string, keywords ...string) (string, map[string]string, error) {
+	words := splitWords(definition)
+	if len(words) < 3 || !strings.EqualFold(words[0].text(definition), "DEFINE") || !strings.EqualFold(words[1].text(definition), kind) {
+		return "", nil, errs.ErrUnmarshal.Withf("invalid definition %q, expected DEFINE %s", definition, kind)
+	}
+
+	words = words[2:]
+	if w := words[0].text(definition); w == "OVERWRITE" {
+		words = words[1:]
+	} else if w == "IF" && len(words) > 3 {
+		// IF NOT EXISTS
+		words = words[3:]
+	}
+	if len(words) == 0 {
+		return "", nil, errs.ErrUnmarshal.Withf("invalid definition %q, missing name", definition)
+	}
+	name := unescapePath(words[0].text(definition))
+
+	clauses := make(map[string]string)
+	var keyword string
+	var start, end int
+	flush := func() {
+		if keyword != "" {
+			clauses[keyword] = strings.TrimSpace(definition[start:end])
+		}
+	}
+	for _, w := range words[1:] {
+		if text := w.text(definition); slices.Contains(keywords, text) {
+			flush()
+			keyword, start, end = text, w.end, w.end
+		} else {
+			end = w.end
+		}
+	}
+	flush()
+	return name, clauses, nil
+}
+
+type word struct {
+	start, end int
+}
+
+func (w word) text(s string) string {
+	return s[w.start:w.end]
+}
+
+// splitWords splits s at whitespace outside of strings and brackets.
+func splitWords(s string) []word {
+	var words []word
+	start := -1
+	scan(s, func(i int, r rune, depth int) {
+		switch {
+		case depth == 0 && unicode.IsSpace(r):
+			if start >= 0 {
+				words = append(words, word{start, i})
+				start = -1
+			}
+		case start < 0:
+			start = i
+		}
+	})
+	if start >= 0 {
+		words = append(words, word{start, len(s)})
+	}
+	return words
+}
+
+// splitTopLevel splits s at sep outside of strings and brackets.
+func splitTopLevel(s string, sep rune) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var parts []string
+	start := 0
+	scan(s, func(i int, r rune, depth int) {
+		if depth == 0 && r == sep {
+			parts = append(parts, s[start:i])
+			start = i + len(string(r))
+		}
+	})
+	return append(parts, s[start:])
+}
+
+// scan calls fn for every rune of s outside of strings, escaped identifiers
+// and record ids with the nesting depth of brackets. Angle brackets only count
+// if they follow a name, like in option<int>, so comparisons and casts do not
+// nest. The opening quote of a string is reported, so that it starts a word.
+func scan(s string, fn func(i int, r rune, depth int)) {
+	var stack []rune
+	var closing, prev rune
+	var escaped bool
+	for i, r := range s {
+		switch {
+		case closing != 0:
+			if escaped {
+				escaped = false
+			} else if r == '\\' && closing != '⟩' {
+				escaped = true
+			} else if r == closing {
+				closing = 0
+			}
+			prev = r
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			closing = r
+		case r == '⟨':
+			closing = '⟩'
+		case r == '(' || r == '[' || r == '{' || r == '<' && (unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_'):
+			fn(i, r, len(stack))
+			stack = append(stack, r)
+			prev = r
+			continue
+		case r == ')' || r == ']' || r == '}' || r == '>' && len(stack) > 0 && stack[len(stack)-1] == '<':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		fn(i, r, len(stack))
+		prev = r
+	}
+}
+
+// unescapePath removes the backticks and angle brackets of the escaped parts
+// of a field path, e.g. `first name` or ⟨first name⟩.
+func unescapePath(s string) string {
+	parts := splitTopLevel(s, '.')
+	for i, p := range parts {
+		switch {
+		case len(p) >= 2 && p[0] == '`' && p[len(p)-1] == '`':
+			p = strings.ReplaceAll(strings.ReplaceAll(p[1:len(p)-1], "\\`", "`"), `\\`, `\`)
+		case strings.HasPrefix(p, "⟨") && strings.HasSuffix(p, "⟩"):
+			p = strings.TrimSuffix(strings.TrimPrefix(p, "⟨"), "⟩")
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ".")
+}
+
+// unquote returns the content of a string literal.
+func unquote(s string) string {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return s
+	}
+
+	var b strings.Builder
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 == len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		switch i++; body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/internal/dbtest"
+	"github.com/NoBypass/surgo/v2/internal/surgotest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestIntrospect(t *testing.T) {
+	t.Run("tables", func(t *testing.T) {
+		db, srv := dbtest.New(t, func(q surgotest.Query) []any {
+			if q.Query == "INFO FOR DB" {
+				return []any{map[string]any{
+					"analyzers": map[string]any{},
+					"tables": map[string]any{
+						"user":       "DEFINE TABLE user TYPE NORMAL SCHEMAFULL COMMENT 'Registered users, see \\'docs\\'' PERMISSIONS NONE",
+						"audit log":  "DEFINE TABLE `audit log` TYPE ANY SCHEMALESS CHANGEFEED 1d PERMISSIONS FULL",
+						"empty_info": "DEFINE TABLE empty_info TYPE ANY SCHEMALESS PERMISSIONS NONE",
+					},
+				}}
+			}
+			return []any{
+				map[string]any{"events": map[string]any{}, "fields": map[string]any{}, "indexes": map[string]any{}},
+				map[string]any{"fields": nil, "indexes": nil},
+				map[string]any{
+					"fields": map[string]any{
+						"name":           "DEFINE FIELD name ON user TYPE string ASSERT string::len($value) > 0 AND string::len($value) < 100 PERMISSIONS FULL",
+						"email":          "DEFINE FIELD email ON user TYPE string VALUE string::lowercase($value) ASSERT string::is::email($value) PERMISSIONS FULL",
+						"created":        "DEFINE FIELD created ON user TYPE datetime DEFAULT time::now() READONLY PERMISSIONS FOR select FULL, FOR create, update, delete NONE",
+						"nickname":       "DEFINE FIELD nickname ON user TYPE option<string | null> COMMENT \"shown instead of the name\" PERMISSIONS FULL",
+						"settings":       "DEFINE FIELD settings ON user FLEXIBLE TYPE object PERMISSIONS FULL",
+						"address":        "DEFINE FIELD address ON user TYPE option<object> PERMISSIONS FULL",
+						"address.city":   "DEFINE FIELD address.city ON user TYPE option<string> PERMISSIONS FULL",
+						"tags[*]":        "DEFINE FIELD tags[*] ON user TYPE string PERMISSIONS FULL",
+						"`first name`":   "DEFINE FIELD `first name` ON user TYPE string DEFAULT 'first; name' PERMISSIONS FULL",
+						"friends":        "DEFINE FIELD friends ON TABLE user TYPE array<record<user>, 10> PERMISSIONS FULL",
+						"address.street": "DEFINE FIELD address.street ON user TYPE option<string> PERMISSIONS FULL",
+					},
+					"indexes": map[string]any{
+						"user_email": "DEFINE INDEX user_email ON user FIELDS email UNIQUE",
+						"user_city":  "DEFINE INDEX user_city ON user FIELDS name, address.city",
+					},
+				},
+			}
+		})
+
+		d, err := Introspect(db)
+		assert.NoError(t, err)
+		assert.Equal(t, "INFO FOR TABLE `audit log`;\nINFO FOR TABLE empty_info;\nINFO FOR TABLE user;\n", srv.Queries()[1].Query)
+		assert.Len(t, d.Tables, 3)
+		assert.Equal(t, Table{Name: "audit log", Permissions: "FULL"}, d.Tables[0])
+
+		user := d.Table("user")
+		assert.True(t, user.Schemafull)
+		assert.Equal(t, "Registered users, see 'docs'", user.Comment)
+		assert.Equal(t, "NONE", user.Permissions)
+		assert.Equal(t, []Field{
+			{Name: "address", Type: "option<object>", Permissions: "FULL"},
+			{Name: "address.city", Type: "option<string>", Permissions: "FULL"},
+			{Name: "address.street", Type: "option<string>", Permissions: "FULL"},
+			{Name: "created", Type: "datetime", Default: "time::now()", Readonly: true, Permissions: "FOR select FULL, FOR create, update, delete NONE"},
+			{Name: "email", Type: "string", Value: "string::lowercase($value)", Assert: "string::is::email($value)", Permissions: "FULL"},
+			{Name: "first name", Type: "string", Default: "'first; name'", Permissions: "FULL"},
+			{Name: "friends", Type: "array<record<user>, 10>", Permissions: "FULL"},
+			{Name: "name", Type: "string", Assert: "string::len($value) > 0 AND string::len($value) < 100", Permissions: "FULL"},
+			{Name: "nickname", Type: "option<string | null>", Permissions: "FULL", Comment: "shown instead of the name"},
+			{Name: "settings", Type: "object", Flexible: true, Permissions: "FULL"},
+			{Name: "tags[*]", Type: "string", Permissions: "FULL"},
+		}, user.Fields)
+		assert.Equal(t, []Index{
+			{Name: "user_city", Fields: []string{"name", "address.city"}},
+			{Name: "user_email", Fields: []string{"email"}, Unique: true},
+		}, user.Indexes)
+
+		// the parsed definitions can be written again
+		statements := user.Statements()
+		assert.Equal(t, "DEFINE FIELD `first name` ON user TYPE string DEFAULT 'first; name' PERMISSIONS FULL", statements[6])
+		assert.Equal(t, "DEFINE FIELD tags[*] ON user TYPE string PERMISSIONS FULL", statements[11])
+	})
+	t.Run("empty", func(t *testing.T) {
+		db, srv := dbtest.New(t, func(q surgotest.Query) []any {
+			return []any{map[string]any{"tables": map[string]any{}}}
+		})
+
+		d, err := Introspect(db)
+		assert.NoError(t, err)
+		assert.Empty(t, d.Tables)
+		assert.Len(t, srv.Queries(), 1)
+	})
+	t.Run("invalid definition", func(t *testing.T) {
+		db, _ := dbtest.New(t, func(q surgotest.Query) []any {
+			if strings.HasPrefix(q.Query, "INFO FOR DB") {
+				return []any{map[string]any{"tables": map[string]any{"user": "DEFINE TABLE user"}}}
+			}
+			return []any{map[string]any{"fields": map[string]any{"name": "DEFINE INDEX name ON user"}}}
+		})
+
+		_, err := Introspect(db)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.ErrorContains(t, err, "expected DEFINE FIELD")
+	})
+}
@@ -0,0 +1,167 @@
+// Package schema describes SurrealDB tables and their fields. Tables can be
+// derived from Go structs with FromStruct and written as DEFINE statements.
+package schema
+
+import (
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/sql"
+	"strings"
+)
+
+// Database is the schema of a database.
+type Database struct {
+	Tables []Table
+}
+
+// Table is the definition of a table.
+type Table struct {
+	Name string
+	// Schemafull makes SurrealDB reject fields which are not defined.
+	Schemafull bool
+	// Permissions are the permissions of the table, e.g. FULL or
+	// FOR select WHERE published = true. Empty means the default.
+	Permissions string
+	Comment     string
+	Fields      []Field
+	Indexes     []Index
+	// Overwrite replaces existing definitions, which requires SurrealDB 2.0
+	// or later.
+	Overwrite bool
+}
+
+// Field is the definition of a field of a table. Nested fields are named by
+// their path, e.g. address.city or tags[*].
+type Field struct {
+	Name string
+	// Type is the SurrealQL type, e.g. string or option<array<int>>.
+	Type string
+	// Flexible keeps the nested fields of an object which are not defined
+	// in a SCHEMAFULL table.
+	Flexible bool
+	// Default, Value and Assert are SurrealQL expressions.
+	Default     string
+	Value       string
+	Assert      string
+	Readonly    bool
+	Permissions string
+	Comment     string
+}
+
+// Index is the definition of an index.
+type Index struct {
+	Name   string
+	Fields []string
+	Unique bool
+}
+
+// Statements returns the DEFINE statements of the table, its fields and its
+// indexes.
+func (t *Table) Statements() []string {
+	statements := []string{t.define("TABLE", sql.Ident(t.Name))}
+	if t.Schemafull {
+		statements[0] += " SCHEMAFULL"
+	} else {
+		statements[0] += " SCHEMALESS"
+	}
+	statements[0] += clause("PERMISSIONS", t.Permissions) + comment(t.Comment)
+
+	for _, f := range t.Fields {
+		s := t.define("FIELD", fieldPath(f.Name)) + " ON " + sql.Ident(t.Name)
+		if f.Flexible {
+			s += " FLEXIBLE"
+		}
+		s += clause("TYPE", f.Type) + clause("DEFAULT", f.Default)
+		if f.Readonly {
+			s += " READONLY"
+		}
+		s += clause("VALUE", f.Value) + clause("ASSERT", f.Assert) + clause("PERMISSIONS", f.Permissions) + comment(f.Comment)
+		statements = append(statements, s)
+	}
+
+	for _, idx := range t.Indexes {
+		fields := make([]string, len(idx.Fields))
+		for i, f := range idx.Fields {
+			fields[i] = fieldPath(f)
+		}
+		s := t.define("INDEX", sql.Ident(idx.Name)) + " ON " + sql.Ident(t.Name) + " FIELDS " + strings.Join(fields, ", ")
+		if idx.Unique {
+			s += " UNIQUE"
+		}
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// String returns the DEFINE statements as a single query.
+func (t *Table) String() string {
+	return strings.Join(t.Statements(), ";\n") + ";"
+}
+
+// Apply defines the table on the database. It returns the error of the first
+// statement which failed.
+func (t *Table) Apply(db *surgo.DB) error {
+	result := db.Query(t.String(), nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	for _, q := range result.Queries {
+		if q.Status == "ERR" {
+			return q.Error
+		}
+	}
+	return nil
+}
+
+// String returns the DEFINE statements of all tables as a single query.
+func (d *Database) String() string {
+	tables := make([]string, len(d.Tables))
+	for i := range d.Tables {
+		tables[i] = d.Tables[i].String()
+	}
+	return strings.Join(tables, "\n\n")
+}
+
+// Table returns the table with the name or nil if there is none.
+func (d *Database) Table(name string) *Table {
+	for i := range d.Tables {
+		if d.Tables[i].Name == name {
+			return &d.Tables[i]
+		}
+	}
+	return nil
+}
+
+func (t *Table) define(kind, name string) string {
+	if t.Overwrite {
+		return "DEFINE " + kind + " OVERWRITE " + name
+	}
+	return "DEFINE " + kind + " " + name
+}
+
+// fieldPath escapes the parts of a nested field name.
+func fieldPath(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		suffix := ""
+		for strings.HasSuffix(p, "[*]") {
+			p, suffix = strings.TrimSuffix(p, "[*]"), suffix+"[*]"
+		}
+		parts[i] = sql.Ident(p) + suffix
+	}
+	return strings.Join(parts, ".")
+}
+
+func clause(keyword, expr string) string {
+	if expr == "" {
+		return ""
+	}
+	return " " + keyword + " " + expr
+}
+
+func comment(c string) string {
+	if c == "" {
+		return ""
+	}
+	lit, _ := sql.Literal(c)
+	return " COMMENT " + lit
+}
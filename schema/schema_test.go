@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"errors"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/geo"
+	"github.com/NoBypass/surgo/v2/internal/dbtest"
+	"github.com/NoBypass/surgo/v2/internal/surgotest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `db:"street"`
+	City   string `db:"city" schema:"index=user_city"`
+}
+
+type user struct {
+	ID       string                 `db:"id"`
+	Name     string                 `db:"name" schema:"assert=string::len($value) > 0;index=user_city"`
+	Email    string                 `db:"email" schema:"value=string::lowercase($value);unique"`
+	Age      *int                   `db:"age"`
+	Tags     []string               `db:"tags"`
+	Created  time.Time              `db:"created" schema:"default=time::now();readonly"`
+	TTL      time.Duration          `db:"ttl"`
+	Address  *address               `db:"address"`
+	Previous []address              `db:"previous"`
+	Location geo.Point              `db:"location"`
+	Manager  string                 `db:"manager" schema:"type=option<record<user>>"`
+	Settings map[string]any         `db:"settings"`
+	Nickname surgo.Nullable[string] `db:"nickname"`
+	Salary   surgo.Decimal          `db:"salary" schema:"permissions=FOR select WHERE $auth.admin = true"`
+	Secret   string                 `db:"-"`
+	Avatar   []byte                 `db:"avatar" schema:"comment=PNG, at most 1 \"MB\""`
+	Friends  [2]string              `db:"friends"`
+	Embedded
+}
+
+type Embedded struct {
+	Version int `db:"version"`
+}
+
+func TestFromStruct(t *testing.T) {
+	t.Run("statements", func(t *testing.T) {
+		table, err := FromStruct[user]("user", Options{Permissions: "FULL"})
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Join([]string{
+			"DEFINE TABLE user SCHEMAFULL PERMISSIONS FULL;",
+			"DEFINE FIELD name ON user TYPE string ASSERT string::len($value) > 0;",
+			"DEFINE FIELD email ON user TYPE string VALUE string::lowercase($value);",
+			"DEFINE FIELD age ON user TYPE option<int>;",
+			"DEFINE FIELD tags ON user TYPE array<string>;",
+			"DEFINE FIELD created ON user TYPE datetime DEFAULT time::now() READONLY;",
+			"DEFINE FIELD ttl ON user TYPE duration;",
+			"DEFINE FIELD address ON user TYPE option<object>;",
+			"DEFINE FIELD address.street ON user TYPE option<string>;",
+			"DEFINE FIELD address.city ON user TYPE option<string>;",
+			"DEFINE FIELD previous ON user TYPE array<object>;",
+			"DEFINE FIELD previous[*].street ON user TYPE string;",
+			"DEFINE FIELD previous[*].city ON user TYPE string;",
+			"DEFINE FIELD location ON user TYPE geometry<point>;",
+			"DEFINE FIELD manager ON user TYPE option<record<user>>;",
+			"DEFINE FIELD settings ON user FLEXIBLE TYPE object;",
+			"DEFINE FIELD nickname ON user TYPE option<string | null>;",
+			"DEFINE FIELD salary ON user TYPE decimal PERMISSIONS FOR select WHERE $auth.admin = true;",
+			`DEFINE FIELD avatar ON user TYPE bytes COMMENT "PNG, at most 1 \"MB\"";`,
+			"DEFINE FIELD friends ON user TYPE array<string, 2>;",
+			"DEFINE FIELD version ON user TYPE int;",
+			"DEFINE INDEX user_city ON user FIELDS name, address.city, previous[*].city;",
+			"DEFINE INDEX user_email_unique ON user FIELDS email UNIQUE;",
+		}, "\n"), table.String())
+	})
+	t.Run("options", func(t *testing.T) {
+		type event struct {
+			Kind    string         `json:"kind" schema:"index"`
+			Payload map[string]any `json:"payload"`
+		}
+		table, err := FromStruct[*event]("event log", Options{Schemaless: true, Overwrite: true, FallbackTag: "json"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"DEFINE TABLE OVERWRITE `event log` SCHEMALESS",
+			"DEFINE FIELD OVERWRITE kind ON `event log` TYPE string",
+			"DEFINE FIELD OVERWRITE payload ON `event log` TYPE object",
+			"DEFINE INDEX OVERWRITE `event log_kind_idx` ON `event log` FIELDS kind",
+		}, table.Statements())
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := FromStruct[int]("x", Options{})
+		assert.ErrorIs(t, err, errs.ErrMarshal)
+
+		_, err = FromStruct[struct {
+			C chan int `db:"c"`
+		}]("x", Options{})
+		assert.ErrorIs(t, err, errs.ErrMarshal)
+		assert.ErrorContains(t, err, "field c: unsupported type chan int")
+
+		_, err = FromStruct[struct {
+			S string `db:"s" schema:"unknown"`
+		}]("x", Options{})
+		assert.ErrorContains(t, err, `unknown schema option "unknown"`)
+	})
+}
+
+func TestTable_Apply(t *testing.T) {
+	table := &Table{Name: "user", Schemafull: true, Fields: []Field{{Name: "name", Type: "string"}}}
+
+	t.Run("ok", func(t *testing.T) {
+		db, srv := dbtest.New(t, func(q surgotest.Query) []any {
+			return []any{nil, nil}
+		})
+		assert.NoError(t, table.Apply(db))
+		assert.Equal(t, "DEFINE TABLE user SCHEMAFULL;\nDEFINE FIELD name ON user TYPE string;", srv.Queries()[0].Query)
+	})
+	t.Run("failed statement", func(t *testing.T) {
+		db, _ := dbtest.New(t, func(q surgotest.Query) []any {
+			return []any{nil, errors.New("the table 'user' already exists")}
+		})
+		err := table.Apply(db)
+		assert.ErrorIs(t, err, errs.ErrAlreadyExists)
+	})
+}
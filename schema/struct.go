@@ -0,0 +1,260 @@
+package schema
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/NoBypass/surgo/v2"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/geo"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Options configure FromStruct.
+type Options struct {
+	// Schemaless defines a SCHEMALESS instead of a SCHEMAFULL table.
+	Schemaless bool
+	// Permissions are the permissions of the table.
+	Permissions string
+	// Overwrite replaces existing definitions, see Table.Overwrite.
+	Overwrite bool
+	// FallbackTag is the struct tag used for fields without a db tag, see
+	// marshal.Codec.FallbackTag.
+	FallbackTag string
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	geometryType = reflect.TypeOf((*geo.Geometry)(nil)).Elem()
+	optionalType = reflect.TypeOf((*marshal.Optional)(nil)).Elem()
+)
+
+// scalarTypes are types with a SurrealQL type of their own which are not
+// derived from their kind.
+var scalarTypes = map[reflect.Type]string{
+	timeType:                                 "datetime",
+	durationType:                             "duration",
+	reflect.TypeOf(big.Int{}):                "number",
+	reflect.TypeOf(big.Float{}):              "number",
+	reflect.TypeOf(surgo.Decimal("")):        "decimal",
+	reflect.TypeOf(surgo.UUID{}):             "uuid",
+	reflect.TypeOf([]byte(nil)):              "bytes",
+	reflect.TypeOf(geo.Point{}):              "geometry<point>",
+	reflect.TypeOf(geo.LineString{}):         "geometry<line>",
+	reflect.TypeOf(geo.Polygon{}):            "geometry<polygon>",
+	reflect.TypeOf(geo.MultiPoint{}):         "geometry<multipoint>",
+	reflect.TypeOf(geo.MultiLineString{}):    "geometry<multiline>",
+	reflect.TypeOf(geo.MultiPolygon{}):       "geometry<multipolygon>",
+	reflect.TypeOf(geo.GeometryCollection{}): "geometry<collection>",
+}
+
+// FromStruct derives the definition of the table from the fields of T, which
+// are named according to the struct tags like by marshal.Codec. The
+// SurrealQL types are inferred from the Go types: pointers become option<T>,
+// slices array<T>, time.Time datetime and time.Duration duration. Nested
+// structs are defined as objects with fields of their own. The id field is
+// left out, as SurrealDB defines it itself.
+//
+// The schema tag extends the definition of a field with options separated by
+// semicolons:
+//
+//	type=record<user>    overrides the inferred type
+//	assert=$value > 0    the ASSERT clause
+//	default=time::now()  the DEFAULT clause
+//	value=string::lowercase($value)
+//	readonly             makes the field READONLY
+//	permissions=FOR update NONE
+//	comment=text         the COMMENT of the field
+//	index, index=name    adds the field to an index, fields with the same
+//	                     index name form a composite index
+//	unique, unique=name  like index but the index is UNIQUE
+//
+// For example:
+//
+//	Email string `db:"email" schema:"assert=string::is::email($value);unique"`
+func FromStruct[T any](table string, opts Options) (*Table, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errs.ErrMarshal.Withf("cannot derive a table from %s, it is not a struct", t)
+	}
+
+	d := &deriver{
+		m: &marshal.Codec{FallbackTag: opts.FallbackTag},
+		table: &Table{
+			Name:        table,
+			Schemafull:  !opts.Schemaless,
+			Permissions: opts.Permissions,
+			Overwrite:   opts.Overwrite,
+		},
+		indexes: make(map[string]int),
+	}
+	if err := d.fields(t, "", false); err != nil {
+		return nil, err
+	}
+	return d.table, nil
+}
+
+type deriver struct {
+	m     *marshal.Codec
+	table *Table
+	// indexes are the positions of the indexes of the table by name.
+	indexes map[string]int
+}
+
+// fields adds the fields of the struct type t with the prefix to the table.
+// The fields of optional structs are optional themselves.
+func (d *deriver) fields(t reflect.Type, prefix string, optional bool) error {
+	for _, f := range d.m.Fields(t) {
+		name := prefix + f.Name
+		if name == "id" {
+			continue
+		}
+
+		field := Field{Name: name}
+		var index, unique string
+		for _, opt := range strings.Split(f.Tag.Get("schema"), ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "":
+			case "type":
+				field.Type = value
+			case "assert":
+				field.Assert = value
+			case "default":
+				field.Default = value
+			case "value":
+				field.Value = value
+			case "readonly":
+				field.Readonly = true
+			case "permissions":
+				field.Permissions = value
+			case "comment":
+				field.Comment = value
+			case "index":
+				index = cmp.Or(value, d.table.Name+"_"+strings.ReplaceAll(name, ".", "_")+"_idx")
+			case "unique":
+				unique = cmp.Or(value, d.table.Name+"_"+strings.ReplaceAll(name, ".", "_")+"_unique")
+			default:
+				return errs.ErrMarshal.Withf("field %s: unknown schema option %q", f.Name, key)
+			}
+		}
+
+		inferred := field.Type == ""
+		if inferred {
+			typ, err := surrealType(f.Type)
+			if err != nil {
+				return errs.ErrMarshal.Withf("field %s: %w", f.Name, err)
+			} else if optional && !strings.HasPrefix(typ, "option<") {
+				typ = "option<" + typ + ">"
+			}
+			field.Type = typ
+			// the keys of maps can't be defined, so they are only kept if
+			// the field is flexible
+			field.Flexible = d.table.Schemafull && isDynamic(f.Type)
+		}
+		d.table.Fields = append(d.table.Fields, field)
+		d.index(index, name, false)
+		d.index(unique, name, true)
+
+		if inferred {
+			if err := d.nested(f.Type, name, optional); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nested adds the fields of structs within the field of type t.
+func (d *deriver) nested(t reflect.Type, name string, optional bool) error {
+	if _, ok := scalarTypes[t]; ok || t.Implements(optionalType) || t.Implements(geometryType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return d.nested(t.Elem(), name, true)
+	case reflect.Slice, reflect.Array:
+		return d.nested(t.Elem(), name+"[*]", optional)
+	case reflect.Struct:
+		return d.fields(t, name+".", optional)
+	default:
+		return nil
+	}
+}
+
+// isDynamic reports whether values of type t are objects with arbitrary keys.
+func isDynamic(t reflect.Type) bool {
+	if _, ok := scalarTypes[t]; ok {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return isDynamic(t.Elem())
+	case reflect.Map, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *deriver) index(name, field string, unique bool) {
+	if name == "" {
+		return
+	} else if i, ok := d.indexes[name]; ok {
+		d.table.Indexes[i].Fields = append(d.table.Indexes[i].Fields, field)
+		d.table.Indexes[i].Unique = d.table.Indexes[i].Unique || unique
+		return
+	}
+	d.indexes[name] = len(d.table.Indexes)
+	d.table.Indexes = append(d.table.Indexes, Index{Name: name, Fields: []string{field}, Unique: unique})
+}
+
+// surrealType returns the SurrealQL type of the Go type t.
+func surrealType(t reflect.Type) (string, error) {
+	if typ, ok := scalarTypes[t]; ok {
+		return typ, nil
+	} else if t.Implements(optionalType) {
+		// Nullable[T] is NONE, NULL or a T, which is the result of its Get method
+		get, ok := t.MethodByName("Get")
+		if !ok || get.Type.NumOut() == 0 {
+			return "any", nil
+		}
+		inner, err := surrealType(get.Type.Out(0))
+		return "option<" + inner + " | null>", err
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner, err := surrealType(t.Elem())
+		return "option<" + inner + ">", err
+	case reflect.Bool:
+		return "bool", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int", nil
+	case reflect.Float32, reflect.Float64:
+		return "float", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Slice:
+		inner, err := surrealType(t.Elem())
+		return "array<" + inner + ">", err
+	case reflect.Array:
+		inner, err := surrealType(t.Elem())
+		return fmt.Sprintf("array<%s, %d>", inner, t.Len()), err
+	case reflect.Map, reflect.Struct:
+		return "object", nil
+	case reflect.Interface:
+		return "any", nil
+	default:
+		return "", fmt.Errorf("unsupported type %s", t)
+	}
+}
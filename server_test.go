@@ -0,0 +1,39 @@
+package surgo
+
+import (
+	"github.com/NoBypass/surgo/v2/internal/surgotest"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"testing"
+)
+
+// handlerFunc answers a request to the test server, see surgotest.RPCHandler.
+type handlerFunc = surgotest.RPCHandler
+
+// newTestDB returns a DB connected to a test server which answers requests
+// with h. Signin and version requests are answered by the server itself.
+func newTestDB(t *testing.T, h handlerFunc, opts ...Option) *DB {
+	t.Helper()
+	return newVersionedTestDB(t, surgotest.Version, h, opts...)
+}
+
+// newVersionedTestDB is like newTestDB but the server reports the given
+// version.
+func newVersionedTestDB(t *testing.T, version string, h handlerFunc, opts ...Option) *DB {
+	t.Helper()
+
+	srv := surgotest.NewServer(t, version, h)
+	db, err := Connect(srv.URL, &Credentials{}, append([]Option{WithDisableLogging()}, opts...)...)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// statements answers every query with the given statement results. Errors
+// are sent as failed statements.
+func statements(results ...any) handlerFunc {
+	return func(rpc.Request) (any, *rpc.Error) {
+		return surgotest.Statements(results...), nil
+	}
+}
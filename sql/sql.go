@@ -0,0 +1,204 @@
+// Package sql escapes identifiers and writes Go values as SurrealQL literals,
+// for the parts of a query which can't be bound to vars, such as table and
+// field names. Values should be bound to vars whenever possible.
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keywords are the reserved words of SurrealQL. Identifiers which are one of
+// them would be read as a value, an operator or part of a statement if not
+// escaped, e.g. SELECT * FROM select.
+var keywords = []string{
+	"after", "all", "allinside", "alter", "analyze", "and", "anyinside", "as", "asc", "assert", "at",
+	"before", "begin", "break", "by", "cancel", "changefeed", "collate", "commit", "contains", "containsall",
+	"containsany", "containsnone", "containsnot", "content", "continue", "create", "default", "define",
+	"delete", "desc", "diff", "drop", "else", "end", "explain", "false", "fetch", "for", "from", "group",
+	"if", "ignore", "in", "info", "inside", "insert", "intersects", "into", "is", "kill", "let", "limit",
+	"live", "merge", "noindex", "none", "noneinside", "not", "notinside", "null", "numeric", "omit", "on",
+	"only", "option", "or", "order", "outside", "parallel", "patch", "permissions", "rebuild", "relate",
+	"remove", "replace", "return", "select", "set", "show", "sleep", "split", "start", "tempfiles", "then",
+	"throw", "timeout", "to", "true", "unset", "update", "upsert", "use", "value", "when", "where", "with",
+}
+
+// Ident returns name as a SurrealQL identifier. Names which are not plain
+// identifiers or are reserved words are escaped with backticks, e.g.
+// Ident("first name") returns `first name` and Ident("select") `select`.
+func Ident(name string) string {
+	if isPlain(name) && !slices.Contains(keywords, strings.ToLower(name)) {
+		return name
+	}
+	return "`" + escape(name, '`') + "`"
+}
+
+// ID is the type of record ids which can be formatted by Thing.
+type ID interface {
+	~string | ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// Thing returns the record id of the table, e.g. Thing("user", "tobie")
+// returns user:tobie. String ids which are not plain identifiers, including
+// numeric ones and reserved words, are escaped with ⟨⟩ so that they stay
+// strings.
+func Thing[T ID](table string, id T) string {
+	v := reflect.ValueOf(id)
+	switch {
+	case v.Kind() == reflect.String:
+		return Ident(table) + ":" + thingID(v.String())
+	case v.CanInt():
+		return Ident(table) + ":" + strconv.FormatInt(v.Int(), 10)
+	default:
+		return Ident(table) + ":" + strconv.FormatUint(v.Uint(), 10)
+	}
+}
+
+func thingID(id string) string {
+	if isPlain(id) && !slices.Contains(keywords, strings.ToLower(id)) {
+		return id
+	}
+	return "⟨" + escape(id, '⟩') + "⟩"
+}
+
+// Literal returns v as a SurrealQL literal. v is marshaled with the same
+// rules as vars of DB.Query, e.g. structs become objects and time.Time a
+// datetime. Absent optional values are written as NONE and nil as NULL.
+func Literal(v any) (string, error) {
+	vars := (&marshal.Codec{}).Marshal(map[string]any{"v": v})
+	if _, ok := vars["v"]; !ok {
+		return "NONE", nil
+	}
+
+	var sb strings.Builder
+	if err := writeLiteral(&sb, vars["v"]); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func writeLiteral(b *strings.Builder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("NULL")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		b.WriteString(v.String())
+	case marshal.Prefixed:
+		writePrefixed(b, v)
+	case string:
+		writeString(b, v)
+	case []any:
+		b.WriteString("[")
+		for i, e := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeLiteral(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeString(b, k)
+			b.WriteString(": ")
+			if err := writeLiteral(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	default:
+		// other values, e.g. numbers and types which implement
+		// json.Marshaler, are encoded and decoded again, so that they are
+		// written the same way they are sent to the database
+		data, err := json.Marshal(v)
+		if err != nil {
+			return errs.ErrMarshal.With(err)
+		}
+		var decoded any
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&decoded); err != nil {
+			return errs.ErrMarshal.With(err)
+		}
+		return writeLiteral(b, decoded)
+	}
+	return nil
+}
+
+// writePrefixed writes a datetime, bytes or uuid literal created by the
+// marshaler. Values which are not of that form are written as strings.
+func writePrefixed(b *strings.Builder, p marshal.Prefixed) {
+	s := string(p)
+	if len(s) < 3 || !strings.ContainsRune("dbu", rune(s[0])) || (s[1] != '"' && s[1] != '\'') || s[len(s)-1] != s[1] {
+		writeString(b, s)
+		return
+	}
+	b.WriteByte(s[0])
+	writeString(b, s[2:len(s)-1])
+}
+
+// writeString writes s as a string.
+func writeString(b *strings.Builder, s string) {
+	b.WriteString(`"` + escape(s, '"') + `"`)
+}
+
+// escape escapes backslashes, the closing quote and control characters.
+func escape(s string, quote rune) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\', quote:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// isPlain reports whether s only consists of ASCII letters, digits and
+// underscores and does not start with a digit, so that it can't be read as a
+// number or duration.
+func isPlain(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,269 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// readToken reads a single SurrealQL identifier, record id part or string
+// from the start of s, the way the SurrealDB lexer does. It returns the
+// unescaped value and the rest of s.
+func readToken(s string) (value, rest string, err error) {
+	if s == "" {
+		return "", "", errors.New("empty input")
+	}
+
+	var end rune
+	switch {
+	case s[0] == '`':
+		end, s = '`', s[1:]
+	case strings.HasPrefix(s, "⟨"):
+		end, s = '⟩', s[len("⟨"):]
+	case s[0] == '"':
+		end, s = '"', s[1:]
+	case len(s) > 1 && strings.ContainsRune("dbu", rune(s[0])) && s[1] == '"':
+		end, s = '"', s[2:]
+	default:
+		i := 0
+		for i < len(s) && (s[i] == '_' || s[i] >= '0' && s[i] <= '9' || s[i]|0x20 >= 'a' && s[i]|0x20 <= 'z') {
+			i++
+		}
+		if i == 0 {
+			return "", "", errors.New("no token at " + strconv.Quote(s))
+		}
+		return s[:i], s[i:], nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == end:
+			return b.String(), s[i:], nil
+		case r < 0x20:
+			return "", "", errors.New("unescaped control character")
+		case r != '\\':
+			b.WriteRune(r)
+			continue
+		}
+
+		r, size = utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch r {
+		case 'n':
+			b.WriteRune('\n')
+		case 'r':
+			b.WriteRune('\r')
+		case 't':
+			b.WriteRune('\t')
+		case 'u':
+			n, err := strconv.ParseUint(s[i:min(i+4, len(s))], 16, 32)
+			if err != nil {
+				return "", "", err
+			}
+			b.WriteRune(rune(n))
+			i += 4
+		case utf8.RuneError:
+			return "", "", errors.New("invalid escape")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", "", errors.New("unterminated token")
+}
+
+// readQuoted is readToken for inputs which are known to be quoted.
+func readQuoted(t *testing.T, s string) string {
+	t.Helper()
+	value, rest, err := readToken(s)
+	assert.NoError(t, err, s)
+	assert.Empty(t, rest, "%s is not a single token", s)
+	return value
+}
+
+func TestIdent(t *testing.T) {
+	tests := map[string]string{
+		"user":           "user",
+		"_user2":         "_user2",
+		"first name":     "`first name`",
+		"2fa":            "`2fa`",
+		"null":           "`null`",
+		"NONE":           "`NONE`",
+		"a`b":            "`a\\`b`",
+		"a\\b":           "`a\\\\b`",
+		"user; DELETE x": "`user; DELETE x`",
+		"line\nbreak":    "`line\\nbreak`",
+		"":               "``",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, Ident(name), name)
+	}
+
+	t.Run("reserved words", func(t *testing.T) {
+		tests := map[string]string{
+			"select":   "`select`",
+			"FROM":     "`FROM`",
+			"Where":    "`Where`",
+			"value":    "`value`",
+			"limit":    "`limit`",
+			"start":    "`start`",
+			"order":    "`order`",
+			"group":    "`group`",
+			"fetch":    "`fetch`",
+			"and":      "`and`",
+			"contains": "`contains`",
+			"inside":   "`inside`",
+			"selected": "selected",
+			"orders":   "orders",
+			"user":     "user",
+		}
+		for name, want := range tests {
+			assert.Equal(t, want, Ident(name), name)
+		}
+		for _, kw := range keywords {
+			assert.Equal(t, "`"+kw+"`", Ident(kw), kw)
+		}
+	})
+}
+
+func TestThing(t *testing.T) {
+	type userID string
+
+	assert.Equal(t, "user:tobie", Thing("user", "tobie"))
+	assert.Equal(t, "user:⟨123⟩", Thing("user", "123"))
+	assert.Equal(t, "user:⟨tobie@surrealdb.com⟩", Thing("user", userID("tobie@surrealdb.com")))
+	assert.Equal(t, "user:⟨a\\⟩b⟩", Thing("user", "a⟩b"))
+	assert.Equal(t, "user:123", Thing("user", 123))
+	assert.Equal(t, "user:-1", Thing("user", int8(-1)))
+	assert.Equal(t, "user:18446744073709551615", Thing("user", uint64(math.MaxUint64)))
+	assert.Equal(t, "`user group`:1", Thing("user group", 1))
+	assert.Equal(t, "`select`:⟨from⟩", Thing("select", "from"))
+	assert.Equal(t, "user:⟨NONE⟩", Thing("user", "NONE"))
+}
+
+func TestLiteral(t *testing.T) {
+	type address struct {
+		City string `db:"city"`
+	}
+	type user struct {
+		Name    string        `db:"name"`
+		Tags    []string      `db:"tags"`
+		Address *address      `db:"address"`
+		Born    time.Time     `db:"born"`
+		Score   *big.Int      `db:"score"`
+		Ttl     time.Duration `db:"ttl"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"nil", nil, "NULL"},
+		{"bool", true, "true"},
+		{"int", -42, "-42"},
+		{"float", 1.5, "1.5"},
+		{"string", `say "hi"`, `"say \"hi\""`},
+		{"bytes", []byte{0xca, 0xfe}, `b"CAFE"`},
+		{"datetime", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), `d"2024-01-02T03:04:05Z"`},
+		{"prefixed strings", []string{`d"2024"`, `b'CAFE'`, `u"x"`}, `["d\"2024\"", "b'CAFE'", "u\"x\""]`},
+		{"prefixed", marshal.Prefixed(`u"0190d3e6-4a2b-7c8d-9e0f-123456789abc"`), `u"0190d3e6-4a2b-7c8d-9e0f-123456789abc"`},
+		{"json.Marshaler", json.RawMessage(`{"d":"d\"2024\"","n":1.50}`), `{"d": "d\"2024\"", "n": 1.50}`},
+		{"slice", []any{1, "a", nil}, `[1, "a", NULL]`},
+		{"map", map[string]int{"b": 2, "a b": 1}, `{"a b": 1, "b": 2}`},
+		{"struct", user{
+			Name:  "Tobie",
+			Tags:  []string{"admin"},
+			Born:  time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+			Score: big.NewInt(7),
+			Ttl:   time.Hour,
+		}, `{"address": NULL, "born": d"1990-05-17T00:00:00Z", "name": "Tobie", "score": 7, "tags": ["admin"], "ttl": "1h"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Literal(tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("absent", func(t *testing.T) {
+		got, err := Literal(absent{})
+		assert.NoError(t, err)
+		assert.Equal(t, "NONE", got)
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := Literal(math.NaN())
+		assert.ErrorIs(t, err, errs.ErrMarshal)
+	})
+}
+
+type absent struct{}
+
+func (absent) SurrealOption() (any, bool) { return nil, false }
+
+var _ marshal.Optional = absent{}
+
+var fuzzSeeds = []string{
+	"", "user", "first name", "2fa", "null", "select", "a`b", "a⟩b", `a\b`, `"`, `\`, "\x00", "\n",
+	"⟨x⟩", "`", "a\\`b", "x;DELETE user;--", "d\"2024\"", "ü", "\x7f", "\xff",
+}
+
+func FuzzIdent(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		ident := Ident(name)
+		assert.NotContains(t, keywords, strings.ToLower(ident), ident)
+		got := readQuoted(t, ident)
+		if utf8.ValidString(name) {
+			assert.Equal(t, name, got)
+		}
+	})
+}
+
+func FuzzThing(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s, s)
+	}
+	f.Fuzz(func(t *testing.T, table, id string) {
+		thing := Thing(table, id)
+		tb, rest, err := readToken(thing)
+		assert.NoError(t, err, thing)
+		assert.True(t, strings.HasPrefix(rest, ":"), thing)
+
+		got := readQuoted(t, rest[1:])
+		if utf8.ValidString(table) && utf8.ValidString(id) {
+			assert.Equal(t, table, tb)
+			assert.Equal(t, id, got)
+		}
+	})
+}
+
+func FuzzLiteral(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		lit, err := Literal(s)
+		assert.NoError(t, err)
+
+		assert.Equal(t, byte('"'), lit[0], lit)
+		got := readQuoted(t, lit)
+		if utf8.ValidString(s) {
+			assert.Equal(t, s, got)
+		}
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/NoBypass/surgo/v2/errs"
 	"github.com/NoBypass/surgo/v2/marshal"
 	"github.com/NoBypass/surgo/v2/rpc"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,22 @@ type DB struct {
 	timeout   time.Duration
 	logger    Logger
 
+	// txAttempts and txBackoff configure the retries of DB.Tx.
+	txAttempts int
+	txBackoff  time.Duration
+
+	// varCheck enables CheckVars for every query.
+	varCheck bool
+
+	// strictUnmarshal and mergeUnmarshal configure the Codec results are
+	// unmarshaled with, see codec.
+	strictUnmarshal bool
+	mergeUnmarshal  bool
+
+	// version is the version reported by the server, e.g. surrealdb-2.1.0,
+	// or empty if it is unknown.
+	version string
+
 	// ctx is only populated if WithContext is used.
 	ctx context.Context
 }
@@ -32,9 +50,11 @@ type Credentials struct {
 // Connect connects to a SurrealDB instance and returns a DB object.
 func Connect(url string, creds *Credentials, opts ...Option) (*DB, error) {
 	db := &DB{
-		Marshaler: marshal.Marshaler(""),
-		timeout:   10 * time.Second,
-		logger:    &defaultLogger{},
+		Marshaler:  marshal.Marshaler(""),
+		timeout:    10 * time.Second,
+		logger:     &defaultLogger{},
+		txAttempts: 5,
+		txBackoff:  10 * time.Millisecond,
 	}
 
 	for _, opt := range opts {
@@ -55,6 +75,12 @@ func Connect(url string, creds *Credentials, opts ...Option) (*DB, error) {
 		return nil, errs.ErrInvalidCredentials.With(err)
 	}
 
+	// older servers may not support the version method, in which case
+	// features which depend on it are disabled.
+	if v, err := c.Send(ctx, "version", nil); err == nil {
+		db.version, _ = v.(string)
+	}
+
 	return db, nil
 }
 
@@ -63,12 +89,39 @@ func (db *DB) Close() error {
 	return db.Conn.Close()
 }
 
+// codec returns the Codec with the fallback tag of the Marshaler and the
+// unmarshal options of the DB.
+func (db *DB) codec() *marshal.Codec {
+	return &marshal.Codec{FallbackTag: string(db.Marshaler), Strict: db.strictUnmarshal, Merge: db.mergeUnmarshal}
+}
+
 func (db DB) WithContext(ctx context.Context) *DB {
-	return &DB{
-		Conn:      db.Conn,
-		Marshaler: db.Marshaler,
-		timeout:   db.timeout,
-		logger:    db.logger,
-		ctx:       ctx,
+	db.ctx = ctx
+	return &db
+}
+
+// Version returns the version of the connected SurrealDB instance, e.g.
+// surrealdb-2.1.0, or an empty string if it is unknown.
+func (db *DB) Version() string {
+	return db.version
+}
+
+// versionAtLeast reports whether the version of the server is at least
+// major.minor. An unknown version is never recent enough.
+func (db *DB) versionAtLeast(major, minor int) bool {
+	v := strings.TrimPrefix(db.version, "surrealdb-")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	maj, err1 := strconv.Atoi(parts[0])
+	min, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
 	}
+	return maj > major || maj == major && min >= minor
 }
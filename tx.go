@@ -0,0 +1,318 @@
+package surgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/errs"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Tx is a transaction. Transactions of DB.Tx buffer their queries and send
+// them as a single transactional query once the function passed to DB.Tx
+// returns. Interactive transactions of DB.BeginTx send every query right away
+// and are ended by Commit or Rollback.
+type Tx struct {
+	db *DB
+
+	// calls, vars and err are used by buffered transactions.
+	calls []txCall
+	vars  map[string]any
+	err   error
+
+	// id is the id of an interactive transaction on the server.
+	id   string
+	done bool
+}
+
+// txCall is a buffered call of Tx.Query or Tx.Scan.
+type txCall struct {
+	statements []string
+	result     *Result
+	dest       any
+}
+
+// Tx runs fn in a transaction. The queries of fn are sent when it returns
+// without an error, otherwise nothing is sent. If SurrealDB reports a
+// transaction conflict, fn is run again with exponential backoff, see
+// WithTxRetry, so fn should not have side effects besides its queries.
+func (db *DB) Tx(ctx context.Context, fn func(tx *Tx) error) error {
+	backoff := db.txBackoff
+	for attempt := 1; ; attempt++ {
+		tx := &Tx{
+			db:   db.WithContext(ctx),
+			vars: make(map[string]any),
+		}
+
+		err := fn(tx)
+		if err == nil {
+			err = tx.commit()
+		}
+		if !errors.Is(err, errs.ErrTransactionConflict) || attempt >= db.txAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// BeginTx starts an interactive transaction, in which the results of queries
+// can be used to decide on later queries. It has to be ended with Commit or
+// Rollback. Interactive transactions require SurrealDB 3.0 or later, on older
+// versions errs.ErrUnsupported is returned.
+func (db *DB) BeginTx(ctx context.Context) (*Tx, error) {
+	if !db.versionAtLeast(3, 0) {
+		return nil, errs.ErrUnsupported.Withf("interactive transactions require SurrealDB 3.0 or later, connected to %q", db.version)
+	}
+
+	tx := &Tx{db: db.WithContext(ctx)}
+	res, err := tx.send("begin", nil)
+	if err != nil {
+		return nil, err
+	} else if tx.id, _ = res.(string); tx.id == "" {
+		return nil, errs.ErrMarshal.Withf("invalid response, unexpected transaction id %v", res)
+	}
+	return tx, nil
+}
+
+// Query buffers the query. The returned Result is populated once the
+// transaction was committed. The vars of all queries are sent together, so a
+// var which an earlier query uses with a different value is renamed in the
+// query, e.g. $id becomes $q2_id.
+// In interactive transactions, the query is executed right away.
+func (tx *Tx) Query(query string, vars any) *Result {
+	if tx.id != "" {
+		if tx.done {
+			return &Result{Error: errs.ErrTxDone}
+		}
+		return tx.db.query(tx.id, query, vars)
+	}
+
+	result := &Result{}
+	tx.buffer(query, vars, result, nil)
+	return result
+}
+
+// Scan buffers the query like Query. Once the transaction was committed, the
+// result of its last statement is scanned into dest. In interactive
+// transactions, the result is scanned right away.
+func (tx *Tx) Scan(dest any, query string, vars any) error {
+	if tx.id != "" {
+		return tx.Query(query, vars).ScanLast(dest)
+	}
+	return tx.buffer(query, vars, &Result{}, dest)
+}
+
+// Commit commits an interactive transaction. If SurrealDB reports a conflict,
+// the error matches errs.ErrTransactionConflict and the transaction can be
+// run again.
+func (tx *Tx) Commit() error {
+	return tx.end("commit")
+}
+
+// Rollback cancels an interactive transaction. It returns errs.ErrTxDone if
+// the transaction has already ended, so it can be deferred right after
+// BeginTx.
+func (tx *Tx) Rollback() error {
+	return tx.end("cancel")
+}
+
+func (tx *Tx) end(method string) error {
+	if tx.id == "" {
+		return errs.ErrUnsupported.Withf("only interactive transactions of DB.BeginTx can be ended explicitly")
+	} else if tx.done {
+		return errs.ErrTxDone
+	}
+
+	tx.done = true
+	_, err := tx.send(method, []any{tx.id})
+	return err
+}
+
+func (tx *Tx) send(method string, params []any) (any, error) {
+	ctx, cancel := context.WithTimeout(safeContext(tx.db.ctx), tx.db.timeout)
+	defer cancel()
+	return tx.db.Conn.Send(ctx, method, params)
+}
+
+func (tx *Tx) buffer(query string, vars any, result *Result, dest any) error {
+	marshaled, err := tx.db.codec().MarshalVars(vars)
+	if err != nil {
+		tx.err = errors.Join(tx.err, err)
+		result.Error = err
+		return err
+	}
+
+	// vars which an earlier query of the transaction uses with a different
+	// value are renamed, e.g. $p1 of the second query becomes $q2_p1
+	renames := make(map[string]string)
+	for k, v := range marshaled {
+		name := k
+		for n := len(tx.calls) + 1; tx.conflicts(name, v); n++ {
+			name = fmt.Sprintf("q%d_%s", n, k)
+		}
+		if name != k {
+			renames[k] = name
+		}
+		tx.vars[name] = v
+	}
+	if len(renames) > 0 {
+		query = renameParams(query, renames)
+	}
+
+	statements := splitStatements(query)
+	if len(statements) == 0 {
+		result.Error = errs.ErrNoResult.Withf("empty query")
+		return result.Error
+	}
+
+	tx.calls = append(tx.calls, txCall{
+		statements: statements,
+		result:     result,
+		dest:       dest,
+	})
+	return nil
+}
+
+// conflicts reports whether the var is already used with another value.
+func (tx *Tx) conflicts(name string, v any) bool {
+	current, ok := tx.vars[name]
+	return ok && !reflect.DeepEqual(current, v)
+}
+
+// commit sends the buffered queries and distributes the results among them.
+func (tx *Tx) commit() error {
+	if tx.err != nil {
+		return tx.err
+	} else if len(tx.calls) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	var n int
+	b.WriteString("BEGIN TRANSACTION;\n")
+	for _, c := range tx.calls {
+		for _, s := range c.statements {
+			b.WriteString(s)
+			b.WriteString(";\n")
+		}
+		n += len(c.statements)
+	}
+	b.WriteString("COMMIT TRANSACTION;")
+
+	result := tx.db.Query(b.String(), tx.vars)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	queries := result.Queries
+	if len(queries) == n+2 {
+		// BEGIN and COMMIT have results of their own on some versions
+		queries = queries[1 : n+1]
+	} else if len(queries) != n {
+		return errs.ErrMarshal.Withf("invalid response, %d results for %d statements", len(queries), n)
+	}
+	if err := transactionError(queries); err != nil {
+		return err
+	}
+
+	for _, c := range tx.calls {
+		c.result.Queries, queries = queries[:len(c.statements)], queries[len(c.statements):]
+		if c.dest == nil {
+			continue
+		} else if err := c.result.ScanLast(c.dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transactionError returns the error which caused a transaction to fail. If a
+// statement fails, SurrealDB reports all other statements as not executed.
+func transactionError(queries []Query) error {
+	var first error
+	for _, q := range queries {
+		if q.Status != "ERR" {
+			continue
+		} else if first == nil {
+			first = q.Error
+		}
+		if !strings.Contains(q.Error.Error(), "not executed due to a failed transaction") {
+			return q.Error
+		}
+	}
+	return first
+}
+
+// splitStatements splits a query into its statements. Semicolons in strings,
+// comments, record ids and blocks do not end a statement.
+func splitStatements(query string) []string {
+	var statements []string
+	var depth int
+	rs := []rune(query)
+	start, end := 0, len(rs)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' {
+					i++
+				}
+			}
+		case r == '⟨':
+			for i++; i < len(rs) && rs[i] != '⟩'; i++ {
+			}
+		case r == '#' || r == '-' && next(rs, i) == '-' || r == '/' && next(rs, i) == '/':
+			// a comment at the end of the query would swallow the semicolon
+			// which is appended to the last statement, so it is cut off.
+			comment := i
+			for i++; i < len(rs) && rs[i] != '\n'; i++ {
+			}
+			if i == len(rs) {
+				end = comment
+			}
+		case r == '/' && next(rs, i) == '*':
+			for i += 2; i < len(rs) && !(rs[i] == '*' && next(rs, i) == '/'); i++ {
+			}
+			i++
+		case r == '{' || r == '(' || r == '[':
+			depth++
+		case r == '}' || r == ')' || r == ']':
+			depth--
+		case r == ';' && depth == 0:
+			statements = appendStatement(statements, string(rs[start:i]))
+			start = i + 1
+		}
+	}
+	if start < end {
+		statements = appendStatement(statements, string(rs[start:end]))
+	}
+	return statements
+}
+
+func next(rs []rune, i int) rune {
+	if i+1 < len(rs) {
+		return rs[i+1]
+	}
+	return 0
+}
+
+// appendStatement appends s to statements unless it only consists of
+// whitespace and comments.
+func appendStatement(statements []string, s string) []string {
+	s = strings.TrimSpace(s)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "#") {
+			return append(statements, s)
+		}
+	}
+	return statements
+}
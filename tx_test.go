@@ -0,0 +1,246 @@
+package surgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/qb"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const notExecuted = "The query was not executed due to a failed transaction"
+
+func TestDB_Tx(t *testing.T) {
+	t.Run("buffers queries", func(t *testing.T) {
+		var sent []any
+		db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+			sent = req.Params
+			return statements(nil, map[string]any{"id": "users:john", "name": "John"}, []any{2})(rpc.Request{})
+		})
+
+		var user testUser
+		var count Result
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			assert.NoError(t, tx.Scan(&user, "LET $id = users:john; CREATE $id SET name = $name", map[string]any{"name": "John"}))
+			count = *tx.Query("SELECT count() FROM users GROUP ALL", nil)
+			assert.Empty(t, count.Queries, "results are only available after the commit")
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, testUser{"users:john", "John"}, user)
+		assert.Equal(t, "BEGIN TRANSACTION;\nLET $id = users:john;\nCREATE $id SET name = $name;\nSELECT count() FROM users GROUP ALL;\nCOMMIT TRANSACTION;", sent[0])
+		assert.Equal(t, map[string]any{"name": "John"}, sent[1])
+	})
+	t.Run("populates results", func(t *testing.T) {
+		db := newTestDB(t, statements(1, 2, 3))
+
+		var a, b *Result
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			a = tx.Query("RETURN 1; RETURN 2", nil)
+			b = tx.Query("RETURN 3", nil)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, a.Queries, 2)
+		var n int
+		assert.NoError(t, b.ScanFirst(&n))
+		assert.Equal(t, 3, n)
+	})
+	t.Run("nothing is sent on error", func(t *testing.T) {
+		var calls atomic.Int32
+		db := newTestDB(t, func(rpc.Request) (any, *rpc.Error) {
+			calls.Add(1)
+			return nil, nil
+		})
+
+		boom := errors.New("boom")
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			tx.Query("CREATE users:john", nil)
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.Zero(t, calls.Load())
+	})
+	t.Run("returns the failing statement", func(t *testing.T) {
+		db := newTestDB(t, statements(errors.New(notExecuted), errors.New("Database record `users:john` already exists")))
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			tx.Query("CREATE users:jane; CREATE users:john", nil)
+			return nil
+		})
+		assert.ErrorIs(t, err, errs.ErrAlreadyExists)
+	})
+	t.Run("retries conflicts", func(t *testing.T) {
+		var calls atomic.Int32
+		conflict := statements(errors.New("Failed to commit transaction due to a read or write conflict. This transaction can be retried"))
+		db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+			if calls.Add(1) < 3 {
+				return conflict(req)
+			}
+			return statements(1)(req)
+		}, WithTxRetry(3, time.Millisecond))
+
+		var runs int
+		var n int
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			runs++
+			return tx.Scan(&n, "UPDATE counter:a SET n += 1 RETURN VALUE n", nil)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, runs)
+		assert.Equal(t, 1, n)
+	})
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		db := newTestDB(t, statements(errors.New("Transaction conflict: Resource busy. This transaction can be retried")), WithTxRetry(2, time.Millisecond))
+
+		var runs int
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			runs++
+			tx.Query("UPDATE counter:a SET n += 1", nil)
+			return nil
+		})
+		assert.ErrorIs(t, err, errs.ErrTransactionConflict)
+		assert.Equal(t, 2, runs)
+	})
+	t.Run("conflicting vars", func(t *testing.T) {
+		var sent []any
+		db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+			sent = req.Params
+			return statements(nil, nil, nil)(req)
+		})
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			tx.Query("CREATE $id SET name = $name", Vars{"id": "users:john", "name": "John"})
+			tx.Query("CREATE $id SET name = $name, note = '$id'", Vars{"id": "users:jane", "name": "John"})
+			tx.Query("DELETE $id", Vars{"id": "users:bob"})
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "BEGIN TRANSACTION;\nCREATE $id SET name = $name;\nCREATE $q2_id SET name = $name, note = '$id';\nDELETE $q3_id;\nCOMMIT TRANSACTION;", sent[0])
+		assert.Equal(t, map[string]any{"id": "users:john", "q2_id": "users:jane", "q3_id": "users:bob", "name": "John"}, sent[1])
+	})
+	t.Run("query builder", func(t *testing.T) {
+		var sent []any
+		db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+			sent = req.Params
+			return statements([]any{}, []any{})(req)
+		})
+		err := db.Tx(context.Background(), func(tx *Tx) error {
+			query, vars := qb.Update("users").Set("active", false).Where(qb.Lt("age", 18)).Build()
+			tx.Query(query, vars)
+			query, vars = qb.Delete("sessions").Where(qb.Eq("user", "users:john")).Build()
+			tx.Query(query, vars)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "BEGIN TRANSACTION;\nUPDATE users SET active = $p1 WHERE age < $p2;\nDELETE sessions WHERE user = $q2_p1;\nCOMMIT TRANSACTION;", sent[0])
+		assert.Equal(t, map[string]any{"p1": false, "p2": json.Number("18"), "q2_p1": "users:john"}, sent[1])
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := map[string][]string{
+		"":                                  nil,
+		"SELECT * FROM users":               {"SELECT * FROM users"},
+		"RETURN 1; RETURN 2;":               {"RETURN 1", "RETURN 2"},
+		"RETURN 'a;b'; RETURN \"c;\\\"d\"":  {"RETURN 'a;b'", "RETURN \"c;\\\"d\""},
+		"SELECT * FROM ⟨a;b⟩; RETURN `x;y`": {"SELECT * FROM ⟨a;b⟩", "RETURN `x;y`"},
+		"IF $a { CREATE a; CREATE b; }; RETURN 1": {"IF $a { CREATE a; CREATE b; }", "RETURN 1"},
+		"-- a; b\nRETURN 1; // c;\nRETURN 2 # d;": {"-- a; b\nRETURN 1", "// c;\nRETURN 2"},
+		"RETURN /* ; */ 1;\n-- only a comment":    {"RETURN /* ; */ 1"},
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, splitStatements(query), query)
+	}
+}
+
+func TestDB_BeginTx(t *testing.T) {
+	// interactive answers begin, commit and cancel and records the requests.
+	interactive := func(requests *[]rpc.Request) handlerFunc {
+		return func(req rpc.Request) (any, *rpc.Error) {
+			*requests = append(*requests, req)
+			switch req.Method {
+			case "begin":
+				return "tx-1", nil
+			case "commit", "cancel":
+				return nil, nil
+			default:
+				return statements(map[string]any{"n": 1})(req)
+			}
+		}
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		db := newTestDB(t, statements())
+		_, err := db.BeginTx(context.Background())
+		assert.ErrorIs(t, err, errs.ErrUnsupported)
+		assert.Equal(t, "surrealdb-2.1.0", db.Version())
+	})
+	t.Run("commit", func(t *testing.T) {
+		var requests []rpc.Request
+		db := newVersionedTestDB(t, "surrealdb-3.0.0-beta.1", interactive(&requests))
+
+		tx, err := db.BeginTx(context.Background())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer tx.Rollback()
+
+		var res struct {
+			N int `db:"n"`
+		}
+		assert.NoError(t, tx.Scan(&res, "SELECT n FROM ONLY counter:a", nil))
+		assert.Equal(t, 1, res.N)
+		assert.NoError(t, tx.Query("UPDATE counter:a SET n = $n", map[string]any{"n": res.N + 1}).Error)
+		assert.NoError(t, tx.Commit())
+
+		assert.ErrorIs(t, tx.Commit(), errs.ErrTxDone)
+		assert.ErrorIs(t, tx.Rollback(), errs.ErrTxDone)
+		assert.ErrorIs(t, tx.Query("RETURN 1", nil).Error, errs.ErrTxDone)
+
+		methods := make([]string, len(requests))
+		for i, req := range requests {
+			methods[i] = req.Method
+		}
+		assert.Equal(t, []string{"begin", "query", "query", "commit"}, methods)
+		assert.Equal(t, "tx-1", requests[1].Txn)
+		assert.Equal(t, "tx-1", requests[2].Txn)
+		assert.Equal(t, []any{"tx-1"}, requests[3].Params)
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var requests []rpc.Request
+		db := newVersionedTestDB(t, "surrealdb-3.1.0", interactive(&requests))
+
+		tx, err := db.BeginTx(context.Background())
+		if assert.NoError(t, err) {
+			assert.NoError(t, tx.Rollback())
+			assert.Equal(t, "cancel", requests[len(requests)-1].Method)
+		}
+	})
+	t.Run("buffered transactions can't be ended explicitly", func(t *testing.T) {
+		db := newTestDB(t, statements())
+		_ = db.Tx(context.Background(), func(tx *Tx) error {
+			assert.ErrorIs(t, tx.Commit(), errs.ErrUnsupported)
+			return nil
+		})
+	})
+}
+
+func TestDB_versionAtLeast(t *testing.T) {
+	tests := map[string]bool{
+		"surrealdb-3.0.0":         true,
+		"surrealdb-3.0.0-alpha.1": true,
+		"surrealdb-4.2.1+build":   true,
+		"3.1.0":                   true,
+		"surrealdb-2.9.9":         false,
+		"surrealdb-x":             false,
+		"":                        false,
+	}
+	for version, want := range tests {
+		db := &DB{version: version}
+		assert.Equal(t, want, db.versionAtLeast(3, 0), version)
+	}
+}
@@ -0,0 +1,247 @@
+package surgo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// decimalRegex matches a decimal with an optional sign, integer part, fraction
+// and exponent, e.g. -1.5e3 or +.5. The groups are the parts of the number.
+var decimalRegex = regexp.MustCompile(`^([-+]?)(\d*)(\.\d*)?([eE][-+]?\d+)?$`)
+
+// Decimal is an arbitrary precision decimal number. It keeps the exact
+// textual representation it was decoded from, so that values such as money
+// never pass through float64. The zero value is equal to 0.
+type Decimal string
+
+// NewDecimal parses s into a Decimal. The SurrealQL "dec" suffix is accepted.
+func NewDecimal(s string) (Decimal, error) {
+	var d Decimal
+	err := d.UnmarshalText([]byte(s))
+	return d, err
+}
+
+// String returns the textual representation of the decimal.
+func (d Decimal) String() string {
+	if d == "" {
+		return "0"
+	}
+	return string(d)
+}
+
+// Rat returns the decimal as a *big.Rat for exact arithmetic.
+func (d Decimal) Rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) {
+	s, err := normalizeDecimal(d.String())
+	return []byte(s), err
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	s, err := normalizeDecimal(strings.TrimSuffix(string(text), "dec"))
+	if err != nil {
+		return err
+	}
+	*d = Decimal(s)
+	return nil
+}
+
+// MarshalJSON writes the decimal as a number literal, so that no precision
+// is lost on the way to SurrealDB.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return d.MarshalText()
+}
+
+// normalizeDecimal validates s and rewrites it to a valid JSON number, e.g.
+// +.5 to 0.5 and 10. to 10, as the request would fail to encode otherwise.
+func normalizeDecimal(s string) (string, error) {
+	m := decimalRegex.FindStringSubmatch(s)
+	if m == nil || m[2] == "" && len(m[3]) < 2 {
+		return "", fmt.Errorf("invalid decimal %q", s)
+	}
+
+	sign, integer, fraction, exponent := strings.TrimPrefix(m[1], "+"), m[2], m[3], m[4]
+	if integer == "" {
+		integer = "0"
+	}
+	if fraction == "." {
+		fraction = ""
+	}
+	return sign + integer + fraction + exponent, nil
+}
+
+// UUID is a SurrealDB uuid. It is written as a string containing a SurrealQL
+// uuid literal, which SurrealDB stores as a string, as surgo only talks to it
+// over JSON. As UUID is a [16]byte, it can be converted to and from other uuid
+// types of the same layout.
+type UUID [16]byte
+
+// NewUUID returns a random version 4 uuid.
+func NewUUID() UUID {
+	var u UUID
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// ParseUUID parses a uuid in its canonical form. The SurrealQL uuid literal
+// (u"..." or u'...') is accepted as well.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	err := u.UnmarshalText([]byte(s))
+	return u, err
+}
+
+// String returns the canonical form of the uuid, e.g.
+// 01234567-89ab-cdef-0123-456789abcdef.
+func (u UUID) String() string {
+	b := make([]byte, 36)
+	hex.Encode(b, u[:4])
+	b[8] = '-'
+	hex.Encode(b[9:], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	s := string(text)
+	if len(s) > 2 && s[0] == 'u' && (s[1] == '"' || s[1] == '\'') && s[len(s)-1] == s[1] {
+		s = s[2 : len(s)-1]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return fmt.Errorf("invalid uuid %q", text)
+	}
+
+	var parsed UUID
+	for i, j := range []int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34} {
+		if _, err := hex.Decode(parsed[i:i+1], []byte(s[j:j+2])); err != nil {
+			return fmt.Errorf("invalid uuid %q", text)
+		}
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalJSON writes the uuid as a string containing a SurrealQL uuid literal.
+func (u UUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.MarshalPrefixed())
+}
+
+// MarshalPrefixed implements marshal.PrefixedMarshaler.
+func (u UUID) MarshalPrefixed() marshal.Prefixed {
+	return marshal.Prefixed(`u"` + u.String() + `"`)
+}
+
+// Nullable is a value which tells NONE, a field which is absent, apart from
+// NULL. The zero value is NONE. Absent nullables are left out when marshaled,
+// so a NULL nullable can be used to clear a field, e.g. in UPDATE ... MERGE.
+type Nullable[T any] struct {
+	value T
+	state nullableState
+}
+
+type nullableState uint8
+
+const (
+	none nullableState = iota
+	null
+	some
+)
+
+// Some returns a Nullable holding v.
+func Some[T any](v T) Nullable[T] {
+	return Nullable[T]{value: v, state: some}
+}
+
+// Null returns a Nullable which is NULL.
+func Null[T any]() Nullable[T] {
+	return Nullable[T]{state: null}
+}
+
+// None returns a Nullable which is absent. It is equal to the zero value.
+func None[T any]() Nullable[T] {
+	return Nullable[T]{}
+}
+
+// Get returns the value of the nullable and whether it holds one.
+func (o Nullable[T]) Get() (T, bool) {
+	return o.value, o.state == some
+}
+
+// OrElse returns the value of the nullable or v if it holds none.
+func (o Nullable[T]) OrElse(v T) T {
+	if o.state == some {
+		return o.value
+	}
+	return v
+}
+
+// IsNone reports whether the nullable is absent.
+func (o Nullable[T]) IsNone() bool {
+	return o.state == none
+}
+
+// IsNull reports whether the nullable is NULL.
+func (o Nullable[T]) IsNull() bool {
+	return o.state == null
+}
+
+// String returns NONE, NULL or the formatted value of the nullable.
+func (o Nullable[T]) String() string {
+	switch o.state {
+	case none:
+		return "NONE"
+	case null:
+		return "NULL"
+	default:
+		return fmt.Sprint(o.value)
+	}
+}
+
+// SurrealOption implements marshal.Optional.
+func (o Nullable[T]) SurrealOption() (any, bool) {
+	if o.state == some {
+		return o.value, true
+	}
+	return nil, o.state == null
+}
+
+// UnmarshalSurreal implements marshal.SurrealUnmarshaler. A nil src results
+// in NULL. Fields which are not present in the result are not decoded at all
+// and stay NONE.
+func (o *Nullable[T]) UnmarshalSurreal(m *marshal.Codec, src any) error {
+	if src == nil {
+		*o = Null[T]()
+		return nil
+	}
+
+	var v T
+	if err := m.Unmarshal(src, &v); err != nil {
+		return err
+	}
+	*o = Some(v)
+	return nil
+}
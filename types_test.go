@@ -0,0 +1,158 @@
+package surgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/marshal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDecimal(t *testing.T) {
+	m := marshal.Codec{}
+
+	t.Run("parse", func(t *testing.T) {
+		for _, s := range []string{"0", "-1.5", "+.5", "10.00", "1e-10", "19.99dec"} {
+			_, err := NewDecimal(s)
+			assert.NoError(t, err, s)
+		}
+		for _, s := range []string{"", ".", "+", "e5", "abc", "1.2.3", "1e", "NaN"} {
+			_, err := NewDecimal(s)
+			assert.Error(t, err, s)
+		}
+	})
+	t.Run("normalizes to JSON numbers", func(t *testing.T) {
+		for s, want := range map[string]Decimal{"+.5": "0.5", "-.5e3": "-0.5e3", "10.": "10", "+1.50": "1.50", "7.E2": "7E2"} {
+			d, err := NewDecimal(s)
+			assert.NoError(t, err, s)
+			assert.Equal(t, want, d, s)
+		}
+
+		b, err := json.Marshal(map[string]any{"a": Decimal("+.5"), "b": Decimal("10.")})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a":0.5,"b":10}`, string(b))
+
+		_, err = json.Marshal(Decimal("abc"))
+		assert.Error(t, err)
+	})
+	t.Run("unmarshal keeps precision", func(t *testing.T) {
+		type testStruct struct {
+			Price Decimal  `db:"price"`
+			Tax   *Decimal `db:"tax"`
+		}
+		var s testStruct
+		err := m.Unmarshal(map[string]any{
+			"price": json.Number("12345678901234567890.123456789"),
+			"tax":   "0.10dec",
+		}, &s)
+		assert.NoError(t, err)
+		assert.Equal(t, Decimal("12345678901234567890.123456789"), s.Price)
+		assert.Equal(t, Decimal("0.10"), *s.Tax)
+	})
+	t.Run("marshal keeps precision", func(t *testing.T) {
+		vars := m.Marshal(map[string]any{"price": Decimal("12345678901234567890.123456789"), "zero": Decimal("")})
+		b, err := json.Marshal(vars)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"price":12345678901234567890.123456789,"zero":0}`, string(b))
+	})
+	t.Run("rat", func(t *testing.T) {
+		assert.Equal(t, "1/10", Decimal("0.10").Rat().String())
+	})
+}
+
+func TestUUID(t *testing.T) {
+	m := marshal.Codec{}
+	const s = "0190d3e6-4a2b-7c8d-9e0f-123456789abc"
+
+	t.Run("parse", func(t *testing.T) {
+		for _, v := range []string{s, `u"` + s + `"`, `u'` + s + `'`, "0190D3E6-4A2B-7C8D-9E0F-123456789ABC"} {
+			u, err := ParseUUID(v)
+			assert.NoError(t, err, v)
+			assert.Equal(t, s, u.String(), v)
+		}
+		for _, v := range []string{"", "abc", s[:35], "0190d3e6x4a2b-7c8d-9e0f-123456789abc", "0190d3e6-4a2b-7c8d-9e0f-123456789abg", `u"` + s + `'`} {
+			_, err := ParseUUID(v)
+			assert.Error(t, err, v)
+		}
+	})
+	t.Run("new", func(t *testing.T) {
+		u := NewUUID()
+		assert.NotEqual(t, u, NewUUID())
+		assert.Equal(t, byte('4'), u.String()[14])
+	})
+	t.Run("marshal", func(t *testing.T) {
+		u, _ := ParseUUID(s)
+		vars := m.Marshal(map[string]any{"id": u, "ids": []UUID{u}, "nil": (*UUID)(nil)})
+		assert.Equal(t, marshal.Prefixed(`u"`+s+`"`), vars["id"])
+		b, err := json.Marshal(vars)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"u\"`+s+`\"","ids":["u\"`+s+`\""],"nil":null}`, string(b))
+	})
+	t.Run("unmarshal", func(t *testing.T) {
+		type testStruct struct {
+			ID    UUID  `db:"id"`
+			Other *UUID `db:"other"`
+		}
+		var v testStruct
+		err := m.Unmarshal(map[string]any{"id": s, "other": `u"` + s + `"`}, &v)
+		assert.NoError(t, err)
+		assert.Equal(t, s, v.ID.String())
+		assert.Equal(t, s, v.Other.String())
+
+		err = m.Unmarshal(map[string]any{"id": "abc"}, &v)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+	})
+}
+
+func TestNullable(t *testing.T) {
+	m := marshal.Codec{}
+
+	type user struct {
+		Name  Nullable[string]        `db:"name"`
+		Age   Nullable[int]           `db:"age"`
+		Seen  Nullable[time.Duration] `db:"seen"`
+		Email *Nullable[string]       `db:"email"`
+	}
+
+	t.Run("states", func(t *testing.T) {
+		var n Nullable[int]
+		assert.True(t, n.IsNone())
+		assert.Equal(t, None[int](), n)
+		assert.True(t, Null[int]().IsNull())
+		v, ok := Some(0).Get()
+		assert.True(t, ok)
+		assert.Equal(t, 0, v)
+		assert.Equal(t, 5, Null[int]().OrElse(5))
+		assert.Equal(t, "NONE NULL 1", fmt.Sprint(n, Null[int](), Some(1)))
+	})
+	t.Run("marshal", func(t *testing.T) {
+		vars := m.Marshal(map[string]any{
+			"user":   user{Name: Null[string](), Age: Some(0), Seen: Some(time.Second)},
+			"absent": None[int](),
+			"list":   []Nullable[int]{Some(1), Null[int](), None[int]()},
+		})
+		assert.Equal(t, map[string]any{
+			"user": map[string]any{"name": nil, "age": 0, "seen": "1s", "email": nil},
+			"list": []any{1, nil, nil},
+		}, vars)
+	})
+	t.Run("unmarshal", func(t *testing.T) {
+		var u user
+		err := m.Unmarshal(map[string]any{"name": nil, "age": json.Number("0"), "email": "a@b.c"}, &u)
+		assert.NoError(t, err)
+		assert.True(t, u.Name.IsNull())
+		assert.Equal(t, Some(0), u.Age)
+		assert.True(t, u.Seen.IsNone())
+		assert.Equal(t, Some("a@b.c"), *u.Email)
+
+		var n Nullable[int]
+		assert.NoError(t, m.Unmarshal(nil, &n))
+		assert.True(t, n.IsNull())
+
+		err = m.Unmarshal(map[string]any{"age": "old"}, &u)
+		assert.ErrorIs(t, err, errs.ErrUnmarshal)
+		assert.EqualError(t, err, "age: cannot decode string into int")
+	})
+}
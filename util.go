@@ -15,6 +15,9 @@ const (
 	TraceVars
 	TraceResponse
 	TraceEnd
+	// TraceStatement is traced after TraceResponse for every statement with
+	// the Query, which includes its status and execution time.
+	TraceStatement
 )
 
 // TraceType is used to specify the type of trace.
@@ -81,6 +84,42 @@ func WithFallbackTag(tag string) Option {
 	}
 }
 
+// WithStrictUnmarshal makes the DB report unknown fields, missing
+// required fields and type mismatches, see marshal.Codec.Strict.
+func WithStrictUnmarshal() Option {
+	return func(db *DB) {
+		db.strictUnmarshal = true
+	}
+}
+
+// WithTxRetry sets how often DB.Tx runs a transaction which failed because of
+// a conflict and how long it waits before the first retry. The wait is doubled
+// after each retry. The default is 5 attempts and 10ms.
+func WithTxRetry(attempts int, backoff time.Duration) Option {
+	return func(db *DB) {
+		db.txAttempts = attempts
+		db.txBackoff = backoff
+	}
+}
+
+// WithMergeUnmarshal makes the DB keep the current values of the
+// destination for NULL, zero values and missing fields, see
+// marshal.Codec.Merge.
+func WithMergeUnmarshal() Option {
+	return func(db *DB) {
+		db.mergeUnmarshal = true
+	}
+}
+
+// WithVarCheck makes queries fail with errs.ErrVars before they are sent if
+// they use $params which are missing from the vars or if vars are not used,
+// see CheckVars.
+func WithVarCheck() Option {
+	return func(db *DB) {
+		db.varCheck = true
+	}
+}
+
 /* ---------- Misc ---------- */
 
 func safeContext(ctx context.Context) context.Context {
@@ -92,32 +131,48 @@ func safeContext(ctx context.Context) context.Context {
 
 func resultsToQuery(res []any) ([]Query, error) {
 	var results []Query
-	for _, r := range res {
+	for i, r := range res {
 		q := r.(map[string]any)
 		r, ok := q["result"]
 		if !ok {
 			return nil, errs.ErrMarshal.Withf("invalid response, missing result")
 		}
 
-		s, statusOk := q["status"]
+		s, statusOk := q["status"].(string)
 		if !statusOk {
 			return nil, errs.ErrMarshal.Withf("invalid response, missing status")
 		}
 
 		var ee error
 		if str, ok := r.(string); s == "ERR" && ok {
-			ee = errs.ErrDatabase.Withf(str)
+			ee = errs.NewDatabaseError(str, 0, i, nil)
 			r = nil
-		} else if str == "ERR" {
+		} else if s == "ERR" {
 			return nil, errs.ErrMarshal.Withf("invalid response, unexpected error format")
 		} else if r == nil {
 			ee = errs.ErrNoResult
 		}
 
 		results = append(results, Query{
-			Result: r,
-			Error:  ee,
+			Result:   r,
+			Error:    ee,
+			Status:   s,
+			Duration: statementDuration(q["time"]),
 		})
 	}
 	return results, nil
 }
+
+// statementDuration parses the execution time of a statement, e.g. 1.5ms.
+// SurrealDB formats it with fractions, which marshal.ParseDuration does not
+// accept. A missing or invalid time results in 0.
+func statementDuration(t any) time.Duration {
+	s, ok := t.(string)
+	if !ok {
+		return 0
+	} else if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	d, _ := marshal.ParseDuration(s)
+	return d
+}
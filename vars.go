@@ -0,0 +1,159 @@
+package surgo
+
+import (
+	"github.com/NoBypass/surgo/v2/errs"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Vars are the vars of a query. Instead of Vars or another map with string
+// keys, queries also accept structs whose fields become the vars according to
+// the struct tags of the Marshaler.
+type Vars map[string]any
+
+// Set sets the var and returns the vars, so that calls can be chained. A nil
+// Vars is allocated.
+func (v Vars) Set(name string, value any) Vars {
+	if v == nil {
+		v = make(Vars)
+	}
+	v[name] = value
+	return v
+}
+
+// Merge copies all vars of other into v and returns v. A nil Vars is
+// allocated.
+func (v Vars) Merge(other Vars) Vars {
+	if v == nil {
+		v = make(Vars, len(other))
+	}
+	for name, value := range other {
+		v[name] = value
+	}
+	return v
+}
+
+// builtinParams are set by SurrealDB itself.
+var builtinParams = []string{
+	"access", "after", "auth", "before", "event", "input", "parent", "scope", "session", "this", "token", "value",
+}
+
+// declaringKeywords are followed by a param which is declared by the query.
+var declaringKeywords = []string{"let", "for", "param"}
+
+// CheckVars reports $params of the query which are missing from vars and vars
+// which are not used by the query. Params declared by the query itself, e.g.
+// with LET, and the params set by SurrealDB such as $auth are not reported.
+// Params defined with DEFINE PARAM in an earlier query are reported as
+// missing, so the check is disabled by default, see WithVarCheck.
+func CheckVars(query string, vars map[string]any) error {
+	used, declared := queryParams(query)
+
+	var missing, unused []string
+	for name := range used {
+		if _, ok := vars[name]; !ok && !declared[name] && !slices.Contains(builtinParams, name) {
+			missing = append(missing, "$"+name)
+		}
+	}
+	for name := range vars {
+		if !used[name] {
+			unused = append(unused, "$"+name)
+		}
+	}
+	if len(missing) == 0 && len(unused) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unused)
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(missing, ", "))
+	}
+	if len(unused) > 0 {
+		problems = append(problems, "unused "+strings.Join(unused, ", "))
+	}
+	return errs.ErrVars.Withf("%s", strings.Join(problems, "; "))
+}
+
+// queryParams returns the params used by the query and the ones it declares
+// itself. Params in strings, comments and escaped identifiers are ignored.
+func queryParams(query string) (used, declared map[string]bool) {
+	used, declared = make(map[string]bool), make(map[string]bool)
+	rs := []rune(query)
+	scanParams(rs, func(start, end int, isDeclared bool) {
+		name := string(rs[start:end])
+		used[name] = true
+		if isDeclared {
+			declared[name] = true
+		}
+	})
+	return used, declared
+}
+
+// renameParams replaces the params of the query by the names in renames.
+// Params which are not in renames are kept.
+func renameParams(query string, renames map[string]string) string {
+	var b strings.Builder
+	var last int
+	rs := []rune(query)
+	scanParams(rs, func(start, end int, _ bool) {
+		if name, ok := renames[string(rs[start:end])]; ok {
+			b.WriteString(string(rs[last:start]))
+			b.WriteString(name)
+			last = end
+		}
+	})
+	b.WriteString(string(rs[last:]))
+	return b.String()
+}
+
+// scanParams calls fn with the position of the name of every param in rs,
+// i.e. without the $, and whether the query declares the param itself.
+func scanParams(rs []rune, fn func(start, end int, declared bool)) {
+	var word string
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' {
+					i++
+				}
+			}
+		case r == '⟨':
+			for i++; i < len(rs) && rs[i] != '⟩'; i++ {
+			}
+		case r == '#' || r == '-' && next(rs, i) == '-' || r == '/' && next(rs, i) == '/':
+			for i++; i < len(rs) && rs[i] != '\n'; i++ {
+			}
+		case r == '/' && next(rs, i) == '*':
+			for i += 2; i < len(rs) && !(rs[i] == '*' && next(rs, i) == '/'); i++ {
+			}
+			i++
+		case r == '$':
+			start := i + 1
+			for i = start; i < len(rs) && isIdentRune(rs[i]); i++ {
+			}
+			if i > start {
+				// function and closure arguments are declared as $name: type
+				isArg := next(rs, i-1) == ':' && next(rs, i) != ':'
+				fn(start, i, isArg || slices.Contains(declaringKeywords, strings.ToLower(word)))
+			}
+			i--
+			word = ""
+		case isIdentRune(r):
+			start := i
+			for ; i < len(rs) && isIdentRune(rs[i]); i++ {
+			}
+			word = string(rs[start:i])
+			i--
+		case r != ' ' && r != '\t' && r != '\n' && r != '\r':
+			word = ""
+		}
+	}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
@@ -0,0 +1,111 @@
+package surgo
+
+import (
+	"github.com/NoBypass/surgo/v2/errs"
+	"github.com/NoBypass/surgo/v2/rpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVars(t *testing.T) {
+	var v Vars
+	v = v.Set("a", 1).Set("b", 2)
+	assert.Equal(t, Vars{"a": 1, "b": 2}, v)
+	assert.Equal(t, Vars{"a": 1, "b": 3, "c": 4}, v.Merge(Vars{"b": 3, "c": 4}))
+	assert.Equal(t, Vars{"a": 1}, Vars(nil).Merge(Vars{"a": 1}))
+}
+
+func TestDB_Query_vars(t *testing.T) {
+	var sent map[string]any
+	db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+		sent, _ = req.Params[1].(map[string]any)
+		return statements(nil)(req)
+	}, WithFallbackTag("json"))
+
+	t.Run("struct", func(t *testing.T) {
+		type params struct {
+			Name  string        `db:"name"`
+			Limit int           `json:"limit"`
+			TTL   time.Duration `db:"ttl"`
+			Skip  string        `db:"skip,omitempty"`
+		}
+		result := db.Query("SELECT * FROM users WHERE name = $name LIMIT $limit", params{Name: "John", Limit: 10, TTL: time.Minute})
+		assert.NoError(t, result.Error)
+		assert.Equal(t, map[string]any{"name": "John", "limit": "10", "ttl": "1m"}, stringify(sent))
+	})
+	t.Run("struct pointer", func(t *testing.T) {
+		result := db.Query("RETURN $id", &testUser{ID: "users:john"})
+		assert.NoError(t, result.Error)
+		assert.Equal(t, map[string]any{"id": "users:john", "name": ""}, stringify(sent))
+	})
+	t.Run("vars", func(t *testing.T) {
+		result := db.Query("RETURN $a", Vars{}.Set("a", 1))
+		assert.NoError(t, result.Error)
+		assert.Equal(t, map[string]any{"a": "1"}, stringify(sent))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		result := db.Query("RETURN $a", []int{1})
+		assert.ErrorIs(t, result.Error, errs.ErrMarshal)
+	})
+}
+
+// stringify converts the json.Numbers of vars received by the test server
+// into strings.
+func stringify(vars map[string]any) map[string]any {
+	res := make(map[string]any, len(vars))
+	for k, v := range vars {
+		if n, ok := v.(interface{ String() string }); ok {
+			v = n.String()
+		}
+		res[k] = v
+	}
+	return res
+}
+
+func TestCheckVars(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]any
+		err   string
+	}{
+		{"all used", "SELECT * FROM $table WHERE name = $name", map[string]any{"table": 1, "name": 2}, ""},
+		{"missing", "SELECT * FROM users WHERE name = $name AND age > $age", nil, "missing $age, $name"},
+		{"unused", "SELECT * FROM users", map[string]any{"nmae": 1}, "unused $nmae"},
+		{"missing and unused", "RETURN $name", map[string]any{"nmae": 1}, "missing $name; unused $nmae"},
+		{"let", "LET $x = 1; RETURN $x + $y", map[string]any{"y": 1}, ""},
+		{"for", "FOR $u IN $users { UPDATE $u SET ok = true }", map[string]any{"users": 1}, ""},
+		{"function args", "DEFINE FUNCTION fn::add($a: int, $b: int) { RETURN $a + $b }", nil, ""},
+		{"builtin", "SELECT * FROM users WHERE id = $auth.id AND $session.ns = $this", nil, ""},
+		{"strings and comments", "RETURN '$a' + \"$b\" + `$c` + ⟨$d⟩; -- $e\n/* $f */ # $g", nil, ""},
+		{"record ids", "RETURN $id, users:john", map[string]any{"id": 1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVars(tt.query, tt.vars)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errs.ErrVars)
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+
+	t.Run("option", func(t *testing.T) {
+		var sent bool
+		db := newTestDB(t, func(req rpc.Request) (any, *rpc.Error) {
+			sent = true
+			return statements(nil)(req)
+		}, WithVarCheck())
+
+		result := db.Query("SELECT * FROM users WHERE name = $nmae", Vars{"name": "John"})
+		assert.ErrorIs(t, result.Error, errs.ErrVars)
+		assert.False(t, sent)
+
+		result = db.Query("SELECT * FROM users WHERE name = $name", Vars{"name": "John"})
+		assert.NoError(t, result.Error)
+		assert.True(t, sent)
+	})
+}